@@ -0,0 +1,286 @@
+package toolkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to outgoing requests. Set JSONClient.Auth or PushOptions.Auth
+// to authenticate the requests they send; it is called again for every retry.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthError is returned by JSONClient and PushJSONToRemoteContext when their Authenticator
+// fails, for example because no token could be obtained. The default RetryPolicy does not
+// retry it.
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return "cannot authenticate request: " + e.Err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// AuthenticatorFunc lets an ordinary function be used as an Authenticator
+type AuthenticatorFunc func(req *http.Request) error
+
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BearerAuth sends a static token in the Authorization header
+type BearerAuth struct {
+	Token string
+}
+
+func (a BearerAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// BasicAuth sends a username and password with HTTP basic authentication
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// APIKeyAuth sends a static key in a header, X-API-Key by default
+type APIKeyAuth struct {
+	Header string
+	Key    string
+}
+
+func (a APIKeyAuth) Authenticate(req *http.Request) error {
+	header := a.Header
+	if header == "" {
+		header = "X-API-Key"
+	}
+	req.Header.Set(header, a.Key)
+	return nil
+}
+
+// ClientCredentials authenticates requests with an access token obtained through the OAuth2
+// client credentials grant. The token is cached and fetched again shortly before it expires,
+// or when a JSONClient or PushJSONToRemoteContext request using it is answered with 401
+// Unauthorized. Concurrent callers share a single token request. It is safe for concurrent
+// use.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Params are added to the token request, for example an audience
+	Params url.Values
+	// Client sends the token requests, http.DefaultClient by default
+	Client *http.Client
+	// RefreshBefore is how long before its expiry a token is replaced, 1 minute by default and
+	// at most half the lifetime of the token
+	RefreshBefore time.Duration
+	// DefaultLifetime is how long a token issued without expires_in is used, 1 hour by default
+	DefaultLifetime time.Duration
+
+	mu      sync.Mutex
+	token   string
+	refresh time.Time
+	fetch   *tokenFetch
+	now     func() time.Time
+}
+
+// tokenFetch is a token request shared by the callers waiting for it
+type tokenFetch struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// tokenTimeout bounds a token request, which is not cancelled with the caller that started it
+const tokenTimeout = 30 * time.Second
+
+// NewClientCredentials returns a client credentials authenticator for the token endpoint at
+// tokenURL
+func NewClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) *ClientCredentials {
+	return &ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+	}
+}
+
+func (c *ClientCredentials) Authenticate(req *http.Request) error {
+	token, err := c.Token(req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Token returns the cached access token, requesting a new one when there is none or it is
+// about to expire. Callers stop waiting for the new token when the context of req is done,
+// without cancelling the request for the other callers.
+func (c *ClientCredentials) Token(req *http.Request) (string, error) {
+	c.mu.Lock()
+	if c.token != "" && c.clock().Before(c.refresh) {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+	fetch := c.fetch
+	if fetch == nil {
+		fetch = &tokenFetch{done: make(chan struct{})}
+		c.fetch = fetch
+		go c.fetchToken(context.WithoutCancel(req.Context()), fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.token, fetch.err
+	case <-req.Context().Done():
+		return "", req.Context().Err()
+	}
+}
+
+// Invalidate drops the cached token if it is still token, so the next request fetches a new
+// one. A token that was already replaced is kept.
+func (c *ClientCredentials) Invalidate(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// fetchToken requests a token, caches it and hands it to the callers waiting on fetch
+func (c *ClientCredentials) fetchToken(ctx context.Context, fetch *tokenFetch) {
+	ctx, cancel := context.WithTimeout(ctx, tokenTimeout)
+	defer cancel()
+
+	now := c.clock()
+	token, expiresIn, err := c.requestToken(ctx)
+
+	c.mu.Lock()
+	if err == nil {
+		if expiresIn <= 0 {
+			expiresIn = c.DefaultLifetime
+			if expiresIn <= 0 {
+				expiresIn = time.Hour
+			}
+		}
+		refreshBefore := c.RefreshBefore
+		if refreshBefore <= 0 {
+			refreshBefore = time.Minute
+		}
+		refreshBefore = min(refreshBefore, expiresIn/2)
+		c.token = token
+		c.refresh = now.Add(expiresIn - refreshBefore)
+	}
+	c.fetch = nil
+	c.mu.Unlock()
+
+	fetch.token, fetch.err = token, err
+	close(fetch.done)
+}
+
+func (c *ClientCredentials) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// invalidateToken drops the bearer token req was sent with when res rejected it
+func invalidateToken(auth Authenticator, req *http.Request, res *http.Response) {
+	if res.StatusCode != http.StatusUnauthorized {
+		return
+	}
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return
+	}
+	if invalidator, ok := auth.(interface{ Invalidate(token string) }); ok {
+		invalidator.Invalidate(token)
+	}
+}
+
+// requestToken calls the token endpoint and returns the token and its lifetime
+func (c *ClientCredentials) requestToken(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{}
+	for key, values := range c.Params {
+		form[key] = values
+	}
+	form.Set("grant_type", "client_credentials")
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	tokenReq, err := http.NewRequestWithContext(ctx, "POST", c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenReq.Header.Set("Accept", "application/json")
+	tokenReq.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(tokenReq)
+	if err != nil {
+		return "", 0, fmt.Errorf("token request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", 0, fmt.Errorf("token request failed: %w", err)
+	}
+
+	var payload struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.Unmarshal(body, &payload)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message := http.StatusText(res.StatusCode)
+		if err == nil && payload.Error != "" {
+			message = payload.Error
+			if payload.ErrorDescription != "" {
+				message += ": " + payload.ErrorDescription
+			}
+		}
+		return "", 0, fmt.Errorf("token endpoint returned status %d: %s", res.StatusCode, message)
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("token response contains invalid JSON: %w", err)
+	}
+	if payload.AccessToken == "" {
+		return "", 0, errors.New("token response contains no access token")
+	}
+
+	return payload.AccessToken, time.Duration(payload.ExpiresIn) * time.Second, nil
+}
@@ -0,0 +1,259 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuthenticators(t *testing.T) {
+	var tests = []struct {
+		name   string
+		auth   Authenticator
+		header string
+		value  string
+	}{
+		{name: "bearer", auth: BearerAuth{Token: "abc"}, header: "Authorization", value: "Bearer abc"},
+		{name: "basic", auth: BasicAuth{Username: "user", Password: "secret"}, header: "Authorization", value: "Basic dXNlcjpzZWNyZXQ="},
+		{name: "api key", auth: APIKeyAuth{Key: "abc"}, header: "X-API-Key", value: "abc"},
+		{name: "api key header", auth: APIKeyAuth{Header: "Api-Token", Key: "abc"}, header: "Api-Token", value: "abc"},
+		{name: "func", auth: AuthenticatorFunc(func(req *http.Request) error {
+			req.Header.Set("X-Custom", "1")
+			return nil
+		}), header: "X-Custom", value: "1"},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", "http://someurl", nil)
+		err := e.auth.Authenticate(req)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+		if got := req.Header.Get(e.header); got != e.value {
+			t.Errorf("%s: expected %s %q but got %q", e.name, e.header, e.value, got)
+		}
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	var mu sync.Mutex
+	issued := 0
+	fail := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		id, secret, _ := r.BasicAuth()
+		_ = r.ParseForm()
+		if id != "client" || secret != "s3cret" || r.PostForm.Get("grant_type") != "client_credentials" ||
+			r.PostForm.Get("scope") != "read write" || r.PostForm.Get("audience") != "api" {
+			t.Errorf("unexpected token request %s %s %v", id, secret, r.PostForm)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if fail {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"unknown client"}`))
+			return
+		}
+		issued++
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":300}`, issued)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	auth := NewClientCredentials(server.URL, "client", "s3cret", "read", "write")
+	auth.Params = map[string][]string{"audience": {"api"}}
+	auth.now = func() time.Time { return now }
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		fail    bool
+		value   string
+		err     string
+	}{
+		{name: "first request", value: "Bearer token-1"},
+		{name: "cached", advance: 3 * time.Minute, value: "Bearer token-1"},
+		{name: "refreshed before expiry", advance: time.Minute + time.Second, value: "Bearer token-2"},
+		{name: "cached again", value: "Bearer token-2"},
+		{name: "endpoint error", advance: 5 * time.Minute, fail: true, err: "token endpoint returned status 401: invalid_client: unknown client"},
+	}
+
+	for _, e := range steps {
+		now = now.Add(e.advance)
+		mu.Lock()
+		fail = e.fail
+		mu.Unlock()
+
+		req, _ := http.NewRequest("GET", "http://someurl", nil)
+		err := auth.Authenticate(req)
+		if e.err != "" {
+			if err == nil || err.Error() != e.err {
+				t.Errorf("%s: expected error %q but got %v", e.name, e.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+		if got := req.Header.Get("Authorization"); got != e.value {
+			t.Errorf("%s: expected %q but got %q", e.name, e.value, got)
+		}
+	}
+}
+
+func TestAuth_Outbound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var testTools Tools
+
+	client := testTools.NewJSONClient(server.URL)
+	client.Auth = BearerAuth{Token: "abc"}
+	err := client.Get(context.Background(), "/", nil)
+	if err != nil {
+		t.Errorf("json client not authenticated: %s", err)
+	}
+
+	res, status, err := testTools.PushJSONToRemoteContext(context.Background(), server.URL, map[string]int{"n": 1}, PushOptions{Auth: BearerAuth{Token: "abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if status != http.StatusNoContent {
+		t.Errorf("push not authenticated, got status %d", status)
+	}
+
+	calls := 0
+	failing := AuthenticatorFunc(func(req *http.Request) error {
+		calls++
+		return fmt.Errorf("no credentials")
+	})
+	client.Auth = failing
+	err = client.Get(context.Background(), "/", nil)
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || !strings.Contains(err.Error(), "no credentials") {
+		t.Errorf("expected authenticator error but got %v", err)
+	}
+
+	// authenticator errors are not retried
+	calls = 0
+	_, _, err = testTools.PushJSONToRemoteContext(context.Background(), server.URL, map[string]int{"n": 1}, PushOptions{
+		Auth:  failing,
+		Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	})
+	if !errors.As(err, &authErr) || calls != 1 {
+		t.Errorf("expected a single failed attempt but got %d %v", calls, err)
+	}
+}
+
+func TestClientCredentials_NoExpiry(t *testing.T) {
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer"}`, atomic.AddInt32(&issued, 1))
+	}))
+	defer tokenServer.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first token is revoked
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer api.Close()
+
+	now := time.Now()
+	auth := NewClientCredentials(tokenServer.URL, "client", "s3cret")
+	auth.DefaultLifetime = 10 * time.Minute
+	auth.now = func() time.Time { return now }
+
+	req, _ := http.NewRequest("GET", "http://someurl", nil)
+	for i := 0; i < 2; i++ {
+		token, err := auth.Token(req)
+		if err != nil || token != "token-1" {
+			t.Errorf("expected the token without expiry to be cached but got %s %v", token, err)
+		}
+	}
+
+	var testTools Tools
+	client := testTools.NewJSONClient(api.URL)
+	client.Auth = auth
+	if err := client.Get(context.Background(), "/", nil); err == nil {
+		t.Error("expected the revoked token to be rejected")
+	}
+	if err := client.Get(context.Background(), "/", nil); err != nil {
+		t.Errorf("token not replaced after a 401: %s", err)
+	}
+
+	// a late 401 for the replaced token keeps the new one
+	auth.Invalidate("token-1")
+	if token, _ := auth.Token(req); token != "token-2" {
+		t.Errorf("expected the new token to be kept but got %s", token)
+	}
+
+	// the default lifetime ends
+	now = now.Add(10 * time.Minute)
+	if token, _ := auth.Token(req); token != "token-3" {
+		t.Errorf("expected a new token after the default lifetime but got %s", token)
+	}
+}
+
+func TestClientCredentials_Shared(t *testing.T) {
+	var issued int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":300}`, atomic.AddInt32(&issued, 1))
+	}))
+	defer server.Close()
+
+	auth := NewClientCredentials(server.URL, "client", "s3cret")
+
+	// a caller giving up does not wait for the slow endpoint nor cancel the request
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://someurl", nil)
+	if _, err := auth.Token(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the caller to give up but got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 5)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://someurl", nil)
+			tokens[i], _ = auth.Token(req)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, token := range tokens {
+		if token != "token-1" {
+			t.Errorf("expected a single shared token request but got %v", tokens)
+			break
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package toolkit
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bindSources maps the struct tags understood by BindRequest to a description used in errors
+var bindSources = []struct {
+	tag   string
+	label string
+}{
+	{tag: "path", label: "path parameter"},
+	{tag: "query", label: "query parameter"},
+	{tag: "header", label: "header"},
+	{tag: "cookie", label: "cookie"},
+}
+
+// BindRequest fills the struct pointed to by dst from the query parameters, path values
+// (r.PathValue), headers and cookies of r, selected with the query, path, header and cookie
+// struct tags. Adding ",required" to a tag rejects requests where the value is missing.
+//
+//	type ListRequest struct {
+//		OrgID  int           `path:"org"`
+//		Limit  *int          `query:"limit"`
+//		Tags   []string      `query:"tag"`
+//		Since  time.Time     `query:"since"`
+//		Wait   time.Duration `header:"X-Wait"`
+//		Region string        `cookie:"region,required"`
+//	}
+//
+// Supported field types are strings, bools, integers, floats, time.Time (RFC 3339 or a date),
+// time.Duration, types implementing encoding.TextUnmarshaler, and pointers and slices of those.
+// Untagged struct fields are bound recursively.
+func (t *Tools) BindRequest(r *http.Request, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind target must be a non-nil pointer to a struct, got %T", dst)
+	}
+
+	query := r.URL.Query()
+	lookup := func(tag, name string) []string {
+		switch tag {
+		case "path":
+			if v := r.PathValue(name); v != "" {
+				return []string{v}
+			}
+		case "query":
+			return query[name]
+		case "header":
+			return r.Header.Values(name)
+		case "cookie":
+			if c, err := r.Cookie(name); err == nil {
+				return []string{c.Value}
+			}
+		}
+		return nil
+	}
+
+	return bindStruct(rv.Elem(), "", lookup)
+}
+
+func bindStruct(v reflect.Value, prefix string, lookup func(tag, name string) []string) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		path := prefix + fieldName(field)
+		bound := false
+		for _, source := range bindSources {
+			tag, ok := field.Tag.Lookup(source.tag)
+			if !ok || tag == "-" {
+				continue
+			}
+			bound = true
+
+			name, opts, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = field.Name
+			}
+
+			values := lookup(source.tag, name)
+			if len(values) == 0 {
+				if opts == "required" {
+					return fmt.Errorf("%s %q must not be empty", source.label, name)
+				}
+				continue
+			}
+
+			err := setField(v.Field(i), values)
+			if err != nil {
+				return fmt.Errorf("%s %q contains incorrect type for field %q", source.label, name, path)
+			}
+			break
+		}
+
+		if !bound && isNestedStruct(field.Type) {
+			err := bindStruct(v.Field(i), path+".", lookup)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name of a field as ReadJSON reports it, preferring the json tag
+func fieldName(field reflect.StructField) string {
+	if tag := field.Tag.Get("json"); tag != "" {
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// isNestedStruct reports whether a field holds a struct whose fields should be bound recursively
+func isNestedStruct(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ == reflect.TypeOf(time.Time{}) {
+		return false
+	}
+	return !reflect.PointerTo(typ).Implements(textUnmarshalerType)
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setField converts values into the type of v. Slices receive every value, other types the
+// first one.
+func setField(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && !v.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, s := range values {
+			err := setValue(slice.Index(i), s)
+			if err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setValue(v, values[0])
+}
+
+// setValue converts a single string into the type of v
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		err := setValue(ptr.Elem(), s)
+		if err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) && v.Type() != reflect.TypeOf(time.Time{}) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Type() {
+	case reflect.TypeOf(time.Time{}):
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			parsed, err := time.Parse(layout, s)
+			if err == nil {
+				v.Set(reflect.ValueOf(parsed))
+				return nil
+			}
+		}
+		return errors.New("invalid time")
+
+	case reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
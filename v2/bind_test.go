@@ -0,0 +1,120 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindFilter struct {
+	Status string `query:"status"`
+	MinAge uint8  `query:"min_age"`
+}
+
+type bindRequest struct {
+	OrgID   int           `path:"org"`
+	Limit   *int          `query:"limit"`
+	Offset  int           `query:"offset"`
+	Active  bool          `query:"active"`
+	Ratio   float64       `query:"ratio"`
+	Tags    []string      `query:"tag"`
+	IDs     []int64       `query:"id"`
+	Since   time.Time     `query:"since"`
+	Day     *time.Time    `query:"day"`
+	Wait    time.Duration `header:"X-Wait"`
+	Trace   string        `header:"X-Trace-Id"`
+	Region  string        `cookie:"region"`
+	Filter  bindFilter    `json:"filter"`
+	ignored string        `query:"ignored"`
+}
+
+func TestTools_BindRequest(t *testing.T) {
+	var testTools Tools
+
+	mux := http.NewServeMux()
+	var bound bindRequest
+	var err error
+	mux.HandleFunc("GET /orgs/{org}/items", func(w http.ResponseWriter, r *http.Request) {
+		err = testTools.BindRequest(r, &bound)
+	})
+
+	req := httptest.NewRequest("GET", "/orgs/42/items?limit=10&active=true&ratio=0.5&tag=a&tag=b&id=1&id=2&since=2024-01-02T03:04:05Z&day=2024-02-03&status=open&min_age=18&ignored=x", nil)
+	req.Header.Set("X-Wait", "1m30s")
+	req.Header.Set("X-Trace-Id", "abc")
+	req.AddCookie(&http.Cookie{Name: "region", Value: "eu"})
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bound.OrgID != 42 {
+		t.Errorf("wrong path value %d", bound.OrgID)
+	}
+	if bound.Limit == nil || *bound.Limit != 10 {
+		t.Errorf("wrong pointer value %v", bound.Limit)
+	}
+	if bound.Offset != 0 || !bound.Active || bound.Ratio != 0.5 {
+		t.Errorf("wrong scalar values %+v", bound)
+	}
+	if !slices.Equal(bound.Tags, []string{"a", "b"}) || !slices.Equal(bound.IDs, []int64{1, 2}) {
+		t.Errorf("wrong slices %v %v", bound.Tags, bound.IDs)
+	}
+	if !bound.Since.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("wrong time %v", bound.Since)
+	}
+	if bound.Day == nil || !bound.Day.Equal(time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("wrong date %v", bound.Day)
+	}
+	if bound.Wait != 90*time.Second || bound.Trace != "abc" || bound.Region != "eu" {
+		t.Errorf("wrong header or cookie values %+v", bound)
+	}
+	if bound.Filter.Status != "open" || bound.Filter.MinAge != 18 {
+		t.Errorf("wrong nested values %+v", bound.Filter)
+	}
+	if bound.ignored != "" {
+		t.Error("unexported field was bound")
+	}
+}
+
+var bindErrorTests = []struct {
+	name    string
+	url     string
+	message string
+}{
+	{name: "bad int", url: "/?limit=ten", message: `query parameter "limit" contains incorrect type for field "Limit"`},
+	{name: "bad bool", url: "/?active=maybe", message: `query parameter "active" contains incorrect type for field "Active"`},
+	{name: "bad slice element", url: "/?id=1&id=x", message: `query parameter "id" contains incorrect type for field "IDs"`},
+	{name: "bad time", url: "/?since=yesterday", message: `query parameter "since" contains incorrect type for field "Since"`},
+	{name: "overflow", url: "/?min_age=300", message: `query parameter "min_age" contains incorrect type for field "filter.MinAge"`},
+}
+
+func TestTools_BindRequest_Errors(t *testing.T) {
+	var testTools Tools
+	for _, e := range bindErrorTests {
+		var bound bindRequest
+		err := testTools.BindRequest(httptest.NewRequest("GET", e.url, nil), &bound)
+		if err == nil {
+			t.Errorf("%s: error expected but none received", e.name)
+			continue
+		}
+		if err.Error() != e.message {
+			t.Errorf("%s: expected error %q but got %q", e.name, e.message, err.Error())
+		}
+	}
+
+	var required struct {
+		Token string `header:"X-Token,required"`
+	}
+	err := testTools.BindRequest(httptest.NewRequest("GET", "/", nil), &required)
+	if err == nil || !strings.Contains(err.Error(), `header "X-Token" must not be empty`) {
+		t.Errorf("expected required error but got %v", err)
+	}
+
+	err = testTools.BindRequest(httptest.NewRequest("GET", "/", nil), required)
+	if err == nil {
+		t.Error("expected error for non-pointer target")
+	}
+}
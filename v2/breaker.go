@@ -0,0 +1,367 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by PolicyTransport for requests to a host whose circuit breaker is
+// open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of the circuit breaker of a host
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every request until OpenTimeout has passed
+	BreakerOpen
+	// BreakerHalfOpen lets a few trial requests through to find out if the host recovered
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// PolicyTransport is an http.RoundTripper that protects the hosts it calls with a circuit
+// breaker, a limit on concurrent requests and a token bucket rate limiter, each kept per host.
+// Every policy is disabled by its zero value. Use it as the Transport of the http.Client given
+// to PushJSONToRemoteContext, a JSONClient or a Dispatcher.
+type PolicyTransport struct {
+	// Base makes the requests, http.DefaultTransport by default
+	Base http.RoundTripper
+
+	// FailureThreshold is the number of consecutive failures that opens the breaker of a host.
+	// After OpenTimeout (30s by default) the breaker is half-open and lets HalfOpenRequests (1 by
+	// default) trial requests through; it closes when all of them succeed and opens again when
+	// one fails. IsFailure decides what is a failure, by default network errors and 5xx
+	// responses.
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	HalfOpenRequests int
+	IsFailure        func(res *http.Response, err error) bool
+
+	// MaxInFlight limits the concurrent requests to a host. A request holds its slot until its
+	// response body is read to the end or closed. Further requests wait for a slot until their
+	// context is done.
+	MaxInFlight int
+
+	// RateLimit is the number of requests per second allowed to a host, with bursts of up to
+	// Burst requests (1 by default). Requests over the limit wait for a token until their
+	// context is done.
+	RateLimit float64
+	Burst     int
+
+	// OnStateChange is called when the breaker of a host changes state
+	OnStateChange func(host string, from, to BreakerState)
+
+	mu    sync.Mutex
+	hosts map[string]*hostPolicy
+	now   func() time.Time
+}
+
+// hostPolicy is the state kept for one host
+type hostPolicy struct {
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	trials    int
+	successes int
+
+	inflight chan struct{}
+
+	tokens     float64
+	lastRefill time.Time
+}
+
+type breakerTransition struct {
+	host     string
+	from, to BreakerState
+}
+
+// State returns the breaker state of host
+func (p *PolicyTransport) State(host string) BreakerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if h, ok := p.hosts[host]; ok {
+		return h.state
+	}
+	return BreakerClosed
+}
+
+func (p *PolicyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+
+	trial, err := p.allow(host)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.wait(req, host)
+	if err != nil {
+		p.record(host, trial, nil, nil, false)
+		return nil, err
+	}
+
+	release, err := p.acquire(req, host)
+	if err != nil {
+		p.record(host, trial, nil, nil, false)
+		return nil, err
+	}
+
+	base := p.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	res, err := base.RoundTrip(req)
+	if err != nil || p.MaxInFlight <= 0 {
+		release()
+	} else {
+		// the request is in flight until its response body is read or closed
+		res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+	}
+
+	p.record(host, trial, res, err, true)
+	return res, err
+}
+
+// releasingBody frees the in-flight slot of a request once its response body is read to the
+// end or closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+func (p *PolicyTransport) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// host returns the state of a host, creating it if needed. p.mu must be held.
+func (p *PolicyTransport) host(host string) *hostPolicy {
+	if p.hosts == nil {
+		p.hosts = make(map[string]*hostPolicy)
+	}
+	h, ok := p.hosts[host]
+	if !ok {
+		h = &hostPolicy{tokens: float64(p.burst()), lastRefill: p.clock()}
+		if p.MaxInFlight > 0 {
+			h.inflight = make(chan struct{}, p.MaxInFlight)
+		}
+		p.hosts[host] = h
+	}
+	return h
+}
+
+// allow checks the breaker of host and reports whether the request is a half-open trial
+func (p *PolicyTransport) allow(host string) (bool, error) {
+	if p.FailureThreshold <= 0 {
+		return false, nil
+	}
+
+	p.mu.Lock()
+	h := p.host(host)
+	var transitions []breakerTransition
+
+	if h.state == BreakerOpen {
+		openTimeout := p.OpenTimeout
+		if openTimeout <= 0 {
+			openTimeout = 30 * time.Second
+		}
+		if p.clock().Sub(h.openedAt) >= openTimeout {
+			transitions = append(transitions, h.setState(host, BreakerHalfOpen))
+		}
+	}
+
+	var trial bool
+	var err error
+	switch h.state {
+	case BreakerOpen:
+		err = fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+	case BreakerHalfOpen:
+		if h.trials < p.halfOpenRequests() {
+			h.trials++
+			trial = true
+		} else {
+			err = fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+		}
+	}
+	p.mu.Unlock()
+
+	p.notify(transitions)
+	return trial, err
+}
+
+// record updates the breaker of host with the outcome of a request. sent is false when the
+// request was given up before reaching the host, which only frees its trial slot.
+func (p *PolicyTransport) record(host string, trial bool, res *http.Response, err error, sent bool) {
+	if p.FailureThreshold <= 0 {
+		return
+	}
+
+	failed := false
+	if sent {
+		isFailure := p.IsFailure
+		if isFailure == nil {
+			isFailure = func(res *http.Response, err error) bool {
+				return err != nil || res.StatusCode >= 500
+			}
+		}
+		failed = isFailure(res, err)
+	}
+
+	p.mu.Lock()
+	h := p.host(host)
+	var transitions []breakerTransition
+
+	switch {
+	case trial && h.state == BreakerHalfOpen:
+		h.trials--
+		if !sent {
+			break
+		}
+		if failed {
+			transitions = append(transitions, h.setState(host, BreakerOpen))
+			h.openedAt = p.clock()
+		} else {
+			h.successes++
+			if h.successes >= p.halfOpenRequests() {
+				transitions = append(transitions, h.setState(host, BreakerClosed))
+			}
+		}
+
+	case h.state == BreakerClosed && sent:
+		if !failed {
+			h.failures = 0
+			break
+		}
+		h.failures++
+		if h.failures >= p.FailureThreshold {
+			transitions = append(transitions, h.setState(host, BreakerOpen))
+			h.openedAt = p.clock()
+		}
+	}
+	p.mu.Unlock()
+
+	p.notify(transitions)
+}
+
+func (h *hostPolicy) setState(host string, state BreakerState) breakerTransition {
+	transition := breakerTransition{host: host, from: h.state, to: state}
+	h.state = state
+	h.failures = 0
+	h.successes = 0
+	if state != BreakerHalfOpen {
+		h.trials = 0
+	}
+	return transition
+}
+
+func (p *PolicyTransport) notify(transitions []breakerTransition) {
+	if p.OnStateChange == nil {
+		return
+	}
+	for _, t := range transitions {
+		p.OnStateChange(t.host, t.from, t.to)
+	}
+}
+
+func (p *PolicyTransport) halfOpenRequests() int {
+	if p.HalfOpenRequests > 0 {
+		return p.HalfOpenRequests
+	}
+	return 1
+}
+
+func (p *PolicyTransport) burst() int {
+	if p.Burst > 0 {
+		return p.Burst
+	}
+	return 1
+}
+
+// wait takes a token from the bucket of host, waiting for one if it is empty
+func (p *PolicyTransport) wait(req *http.Request, host string) error {
+	if p.RateLimit <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	h := p.host(host)
+	now := p.clock()
+	h.tokens = min(h.tokens+now.Sub(h.lastRefill).Seconds()*p.RateLimit, float64(p.burst()))
+	h.lastRefill = now
+	h.tokens--
+	delay := time.Duration(0)
+	if h.tokens < 0 {
+		delay = time.Duration(-h.tokens / p.RateLimit * float64(time.Second))
+	}
+	p.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		// give the reserved token back
+		p.mu.Lock()
+		h.tokens++
+		p.mu.Unlock()
+		return req.Context().Err()
+	}
+}
+
+// acquire takes an in-flight slot for host and returns the function releasing it
+func (p *PolicyTransport) acquire(req *http.Request, host string) (func(), error) {
+	if p.MaxInFlight <= 0 {
+		return func() {}, nil
+	}
+
+	p.mu.Lock()
+	inflight := p.host(host).inflight
+	p.mu.Unlock()
+
+	select {
+	case inflight <- struct{}{}:
+		return func() { <-inflight }, nil
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
@@ -0,0 +1,190 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func policyRequest(t *testing.T, ctx context.Context, url string) *http.Request {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestPolicyTransport_Breaker(t *testing.T) {
+	status := http.StatusInternalServerError
+	calls := 0
+	now := time.Unix(1700000000, 0)
+
+	var transitions []string
+	transport := &PolicyTransport{
+		Base: RoundTirpFunc(func(req *http.Request) *http.Response {
+			calls++
+			return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewReader(nil))}
+		}),
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		OnStateChange: func(host string, from, to BreakerState) {
+			transitions = append(transitions, fmt.Sprintf("%s %s->%s", host, from, to))
+		},
+		now: func() time.Time { return now },
+	}
+
+	send := func() error {
+		_, err := transport.RoundTrip(policyRequest(t, context.Background(), "http://api.example.com/"))
+		return err
+	}
+
+	steps := []struct {
+		name    string
+		status  int
+		advance time.Duration
+		open    bool
+		calls   int
+		state   BreakerState
+	}{
+		{name: "first failure", status: 500, calls: 1, state: BreakerClosed},
+		{name: "second failure opens", status: 500, calls: 2, state: BreakerOpen},
+		{name: "open rejects", status: 200, open: true, calls: 2, state: BreakerOpen},
+		{name: "still open", status: 200, advance: 30 * time.Second, open: true, calls: 2, state: BreakerOpen},
+		{name: "failed trial reopens", status: 500, advance: 30 * time.Second, calls: 3, state: BreakerOpen},
+		{name: "reopened rejects", status: 200, open: true, calls: 3, state: BreakerOpen},
+		{name: "trial closes", status: 200, advance: time.Minute, calls: 4, state: BreakerClosed},
+		{name: "closed", status: 500, calls: 5, state: BreakerClosed},
+	}
+
+	for _, e := range steps {
+		status = e.status
+		now = now.Add(e.advance)
+
+		err := send()
+		if e.open != errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+		}
+		if calls != e.calls {
+			t.Errorf("%s: expected %d calls but got %d", e.name, e.calls, calls)
+		}
+		if state := transport.State("api.example.com"); state != e.state {
+			t.Errorf("%s: expected state %s but got %s", e.name, e.state, state)
+		}
+	}
+
+	expected := []string{
+		"api.example.com closed->open",
+		"api.example.com open->half-open",
+		"api.example.com half-open->open",
+		"api.example.com open->half-open",
+		"api.example.com half-open->closed",
+	}
+	if fmt.Sprint(transitions) != fmt.Sprint(expected) {
+		t.Errorf("expected transitions %v but got %v", expected, transitions)
+	}
+
+	// other hosts are not affected
+	if state := transport.State("other.example.com"); state != BreakerClosed {
+		t.Errorf("expected other host to be closed but got %s", state)
+	}
+}
+
+func TestPolicyTransport_MaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	transport := &PolicyTransport{
+		Base: RoundTirpFunc(func(req *http.Request) *http.Response {
+			if req.URL.Host == "slow.example.com" {
+				<-release
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader([]byte("{}")))}
+		}),
+		MaxInFlight: 1,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	started := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		close(started)
+		res, err := transport.RoundTrip(policyRequest(t, context.Background(), "http://slow.example.com/"))
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+	<-started
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := transport.RoundTrip(policyRequest(t, ctx, "http://slow.example.com/"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected request to wait for a slot but got %v", err)
+	}
+
+	// the slot is held while the response body is open
+	res, err := transport.RoundTrip(policyRequest(t, context.Background(), "http://fast.example.com/"))
+	if err != nil {
+		t.Fatalf("request to another host blocked: %s", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = transport.RoundTrip(policyRequest(t, ctx, "http://fast.example.com/"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected request to wait for the open body but got %v", err)
+	}
+
+	// reading the body to the end frees the slot
+	_, _ = io.ReadAll(res.Body)
+	res, err = transport.RoundTrip(policyRequest(t, context.Background(), "http://fast.example.com/"))
+	if err != nil {
+		t.Fatalf("slot not released after reading the body: %s", err)
+	}
+	res.Body.Close()
+
+	close(release)
+	wg.Wait()
+
+	res, err = transport.RoundTrip(policyRequest(t, context.Background(), "http://slow.example.com/"))
+	if err != nil {
+		t.Errorf("slot not released: %s", err)
+	} else {
+		res.Body.Close()
+	}
+}
+
+func TestPolicyTransport_RateLimit(t *testing.T) {
+	transport := &PolicyTransport{
+		Base: RoundTirpFunc(func(req *http.Request) *http.Response {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))}
+		}),
+		RateLimit: 20,
+		Burst:     2,
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := transport.RoundTrip(policyRequest(t, context.Background(), "http://api.example.com/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("third request was not delayed, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	slow := &PolicyTransport{Base: transport.Base, RateLimit: 0.1}
+	_, _ = slow.RoundTrip(policyRequest(t, context.Background(), "http://api.example.com/"))
+	_, err := slow.RoundTrip(policyRequest(t, ctx, "http://api.example.com/"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected rate limited request to give up but got %v", err)
+	}
+}
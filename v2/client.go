@@ -0,0 +1,224 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// JSONClient calls JSON APIs. Request bodies are encoded as JSON, successful responses are
+// decoded into the value passed as out, and unsuccessful responses are returned as a
+// *RemoteError.
+type JSONClient struct {
+	// BaseURL is prepended to the paths given to the request methods, unless they are absolute
+	BaseURL string
+	// Client sends the requests, http.DefaultClient by default
+	Client *http.Client
+	// Headers are added to every request
+	Headers http.Header
+	// Auth adds credentials to every request
+	Auth Authenticator
+	// MaxResponseSize limits response bodies, defaulting to the MaxJSONSize of the Tools that
+	// created the client, or 1MB for a JSONClient literal
+	MaxResponseSize int64
+
+	t *Tools
+}
+
+// NewJSONClient returns a client for the API at baseURL
+func (t *Tools) NewJSONClient(baseURL string, client ...*http.Client) *JSONClient {
+	c := &JSONClient{BaseURL: baseURL, t: t}
+	if len(client) > 0 {
+		c.Client = client[0]
+	}
+	return c
+}
+
+// RemoteError is returned by JSONClient for responses with a status other than 2xx. The error
+// body is decoded when it is an RFC 9457 problem+json document or a JSONResponse.
+type RemoteError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// Message is the message of a JSONResponse or the detail or title of a problem document,
+	// falling back to the status text
+	Message string
+
+	// Type, Title, Detail and Instance are set for problem+json responses
+	Type     string
+	Title    string
+	Detail   string
+	Instance string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("remote returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// Get sends a GET request and decodes the response into out
+func (c *JSONClient) Get(ctx context.Context, path string, out interface{}) error {
+	_, err := c.Do(ctx, http.MethodGet, path, nil, out)
+	return err
+}
+
+// Post sends in as a POST request and decodes the response into out
+func (c *JSONClient) Post(ctx context.Context, path string, in, out interface{}) error {
+	_, err := c.Do(ctx, http.MethodPost, path, in, out)
+	return err
+}
+
+// Put sends in as a PUT request and decodes the response into out
+func (c *JSONClient) Put(ctx context.Context, path string, in, out interface{}) error {
+	_, err := c.Do(ctx, http.MethodPut, path, in, out)
+	return err
+}
+
+// Patch sends in as a PATCH request and decodes the response into out
+func (c *JSONClient) Patch(ctx context.Context, path string, in, out interface{}) error {
+	_, err := c.Do(ctx, http.MethodPatch, path, in, out)
+	return err
+}
+
+// Delete sends a DELETE request and decodes the response into out, which may be nil
+func (c *JSONClient) Delete(ctx context.Context, path string, out interface{}) error {
+	_, err := c.Do(ctx, http.MethodDelete, path, nil, out)
+	return err
+}
+
+// Do sends a request with in encoded as the JSON body, or no body when in is nil, and decodes a
+// successful response into out unless out is nil or the response is empty. The returned
+// response gives access to the status and headers; its body has already been read and closed.
+func (c *JSONClient) Do(ctx context.Context, method, path string, in, out interface{}) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path), body)
+	if err != nil {
+		return nil, err
+	}
+	for header, values := range c.Headers {
+		req.Header[header] = values
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Auth != nil {
+		err = c.Auth.Authenticate(req)
+		if err != nil {
+			return nil, &AuthError{Err: err}
+		}
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if c.Auth != nil {
+		invalidateToken(c.Auth, req, res)
+	}
+
+	maxSize := c.MaxResponseSize
+	if maxSize <= 0 {
+		t := c.t
+		if t == nil {
+			// a JSONClient literal uses the default MaxJSONSize
+			t = &Tools{}
+		}
+		maxSize = int64(t.maxJSONSize())
+	}
+	payload, err := io.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return res, err
+	}
+	if int64(len(payload)) > maxSize {
+		return res, fmt.Errorf("response body must not be larger than %d bytes", maxSize)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res, remoteError(res, payload)
+	}
+
+	if out == nil || len(bytes.TrimSpace(payload)) == 0 {
+		return res, nil
+	}
+	err = json.Unmarshal(payload, out)
+	if err != nil {
+		return res, fmt.Errorf("response contains invalid JSON: %w", err)
+	}
+	return res, nil
+}
+
+func (c *JSONClient) url(path string) string {
+	if c.BaseURL == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if path == "" {
+		return c.BaseURL
+	}
+	return strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// remoteError builds the error for an unsuccessful response
+func remoteError(res *http.Response, body []byte) *RemoteError {
+	e := &RemoteError{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       body,
+		Message:    http.StatusText(res.StatusCode),
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/problem+json":
+		var problem struct {
+			Type     string `json:"type"`
+			Title    string `json:"title"`
+			Detail   string `json:"detail"`
+			Instance string `json:"instance"`
+		}
+		if json.Unmarshal(body, &problem) == nil {
+			e.Type, e.Title, e.Detail, e.Instance = problem.Type, problem.Title, problem.Detail, problem.Instance
+			if problem.Detail != "" {
+				e.Message = problem.Detail
+			} else if problem.Title != "" {
+				e.Message = problem.Title
+			}
+		}
+
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var response JSONResponse
+		if json.Unmarshal(body, &response) == nil && response.Message != "" {
+			e.Message = response.Message
+		}
+	}
+
+	if e.Message == "" {
+		e.Message = "unknown error"
+	}
+	return e
+}
+
+// IsRemoteStatus reports whether err is a *RemoteError with the given status code
+func IsRemoteStatus(err error, status int) bool {
+	var remote *RemoteError
+	return errors.As(err, &remote) && remote.StatusCode == status
+}
@@ -0,0 +1,169 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type clientUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func newClientTestServer(t *testing.T) *httptest.Server {
+	var tools Tools
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/users/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("missing client header")
+		}
+		_ = tools.WriteJSON(w, http.StatusOK, clientUser{ID: 1, Name: "John"})
+	})
+	mux.HandleFunc("POST /api/users", func(w http.ResponseWriter, r *http.Request) {
+		var user clientUser
+		err := tools.ReadJSON(w, r, &user)
+		if err != nil {
+			_ = tools.ErrorJSON(w, err)
+			return
+		}
+		user.ID = 2
+		_ = tools.WriteJSON(w, http.StatusCreated, user)
+	})
+	mux.HandleFunc("PUT /api/users/1", func(w http.ResponseWriter, r *http.Request) {
+		_ = tools.ErrorJSON(w, errors.New("user is locked"), http.StatusConflict)
+	})
+	mux.HandleFunc("PATCH /api/users/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"type": "https://example.com/invalid", "title": "Invalid user", "detail": "name is too short", "instance": "/api/users/1"}`))
+	})
+	mux.HandleFunc("DELETE /api/users/1", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /api/large", func(w http.ResponseWriter, r *http.Request) {
+		_ = tools.WriteJSON(w, http.StatusOK, strings.Repeat("a", 100))
+	})
+	mux.HandleFunc("GET /api/text", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gateway timeout", http.StatusGatewayTimeout)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestJSONClient(t *testing.T) {
+	server := newClientTestServer(t)
+	defer server.Close()
+
+	var testTools Tools
+	client := testTools.NewJSONClient(server.URL + "/api/")
+	client.Headers = http.Header{"Authorization": []string{"Bearer token"}}
+	ctx := context.Background()
+
+	var user clientUser
+	err := client.Get(ctx, "/users/1", &user)
+	if err != nil || user != (clientUser{ID: 1, Name: "John"}) {
+		t.Errorf("get: unexpected result %+v %v", user, err)
+	}
+
+	var created clientUser
+	err = client.Post(ctx, "users", clientUser{Name: "Jane"}, &created)
+	if err != nil || created != (clientUser{ID: 2, Name: "Jane"}) {
+		t.Errorf("post: unexpected result %+v %v", created, err)
+	}
+
+	res, err := client.Do(ctx, http.MethodDelete, "users/1", nil, nil)
+	if err != nil || res.StatusCode != http.StatusNoContent {
+		t.Errorf("delete: unexpected result %v", err)
+	}
+	if err = client.Delete(ctx, "users/1", &user); err != nil {
+		t.Errorf("delete: unexpected error %s", err)
+	}
+
+	// a literal works with the default settings
+	literal := &JSONClient{BaseURL: server.URL + "/api/", Headers: client.Headers}
+	err = literal.Get(ctx, "/users/1", &user)
+	if err != nil || user != (clientUser{ID: 1, Name: "John"}) {
+		t.Errorf("literal: unexpected result %+v %v", user, err)
+	}
+}
+
+func TestJSONClient_Errors(t *testing.T) {
+	server := newClientTestServer(t)
+	defer server.Close()
+
+	var testTools Tools
+	client := testTools.NewJSONClient(server.URL + "/api")
+	client.Headers = http.Header{"Authorization": []string{"Bearer token"}}
+	ctx := context.Background()
+
+	small := testTools.NewJSONClient(server.URL + "/api")
+	small.MaxResponseSize = 50
+
+	tests := []struct {
+		name    string
+		call    func() error
+		status  int
+		message string
+	}{
+		{
+			name:    "json response",
+			call:    func() error { return client.Put(ctx, "users/1", clientUser{}, nil) },
+			status:  http.StatusConflict,
+			message: "remote returned status 409: user is locked",
+		},
+		{
+			name:    "validation",
+			call:    func() error { return client.Post(ctx, "users", map[string]int{"name": 1}, nil) },
+			status:  http.StatusBadRequest,
+			message: `remote returned status 400: body contains incorrect JSON type for field "name"`,
+		},
+		{
+			name:    "problem",
+			call:    func() error { return client.Patch(ctx, "users/1", clientUser{}, nil) },
+			status:  http.StatusUnprocessableEntity,
+			message: "remote returned status 422: name is too short",
+		},
+		{
+			name:    "plain text",
+			call:    func() error { return client.Get(ctx, "text", nil) },
+			status:  http.StatusGatewayTimeout,
+			message: "remote returned status 504: Gateway Timeout",
+		},
+		{
+			name:    "not found",
+			call:    func() error { return client.Get(ctx, "missing", nil) },
+			status:  http.StatusNotFound,
+			message: "remote returned status 404: Not Found",
+		},
+		{
+			name:    "too large",
+			call:    func() error { return small.Get(ctx, "large", nil) },
+			message: "response body must not be larger than 50 bytes",
+		},
+	}
+
+	for _, e := range tests {
+		err := e.call()
+		if err == nil || err.Error() != e.message {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.message, err)
+			continue
+		}
+		if e.status != 0 && !IsRemoteStatus(err, e.status) {
+			t.Errorf("%s: expected remote status %d", e.name, e.status)
+		}
+	}
+
+	err := client.Patch(ctx, "users/1", clientUser{}, nil)
+	var remote *RemoteError
+	if !errors.As(err, &remote) {
+		t.Fatalf("expected remote error but got %v", err)
+	}
+	if remote.Type != "https://example.com/invalid" || remote.Title != "Invalid user" || remote.Instance != "/api/users/1" {
+		t.Errorf("problem details not decoded %+v", remote)
+	}
+}
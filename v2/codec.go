@@ -0,0 +1,363 @@
+package toolkit
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Codec encodes and decodes request and response bodies of a single media type. Codecs for
+// formats such as MessagePack or CBOR can be added to Tools.Codecs or with RegisterCodec.
+type Codec interface {
+	ContentType() string
+	Decode(r io.Reader, v interface{}) error
+	Encode(w io.Writer, v interface{}) error
+}
+
+// RegisterCodec adds c to the codecs used by ReadBody and WriteBody. A codec replaces any
+// built-in or previously registered codec with the same content type.
+func (t *Tools) RegisterCodec(c Codec) {
+	for i, x := range t.Codecs {
+		if strings.EqualFold(x.ContentType(), c.ContentType()) {
+			t.Codecs[i] = c
+			return
+		}
+	}
+	t.Codecs = append(t.Codecs, c)
+}
+
+// codecs returns the built-in codecs merged with the registered ones. JSON comes first, so it is
+// used whenever the client does not express a preference.
+func (t *Tools) codecs() []Codec {
+	codecs := []Codec{
+		jsonCodec{t: t},
+		xmlCodec{contentType: "application/xml"},
+		xmlCodec{contentType: "text/xml"},
+		formCodec{t: t},
+	}
+
+	for _, c := range t.Codecs {
+		replaced := false
+		for i, x := range codecs {
+			if strings.EqualFold(x.ContentType(), c.ContentType()) {
+				codecs[i] = c
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			codecs = append(codecs, c)
+		}
+	}
+
+	return codecs
+}
+
+// ReadBody decodes the request body into data using the codec selected by the Content-Type
+// header. A request without a Content-Type is decoded as JSON. The body is subject to the same
+// size limit and decompression as ReadJSON, and an unsupported content type results in a 415
+// StatusError.
+func (t *Tools) ReadBody(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	codec, err := t.requestCodec(r)
+	if err != nil {
+		return err
+	}
+
+	err = t.limitBody(w, r)
+	if err != nil {
+		return err
+	}
+
+	err = codec.Decode(r.Body, data)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteBody encodes data with the codec that best matches the Accept header of the request and
+// writes it with the given status. When no codec is acceptable to the client a 406 StatusError
+// is returned and nothing is written. When CompressResponses is set large bodies are gzipped for
+// clients that accept it.
+func (t *Tools) WriteBody(w http.ResponseWriter, r *http.Request, status int, data interface{}, headers ...http.Header) error {
+	codec, err := t.responseCodec(r)
+	if err != nil {
+		return err
+	}
+
+	if t.CompressResponses {
+		var done func()
+		w, done = t.compressResponse(w, r)
+		defer done()
+	}
+
+	if _, ok := codec.(jsonCodec); ok {
+		w.Header().Add("Vary", "Accept")
+		return t.writeJSON(w, r, status, data, headers...)
+	}
+
+	var buf strings.Builder
+	err = codec.Encode(&buf, data)
+	if err != nil {
+		return err
+	}
+
+	if len(headers) > 0 {
+		for key, value := range headers[0] {
+			w.Header()[key] = value
+		}
+	}
+
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", codec.ContentType())
+	w.WriteHeader(status)
+	_, err = io.WriteString(w, buf.String())
+	return err
+}
+
+// requestCodec selects the codec for the Content-Type of the request
+func (t *Tools) requestCodec(r *http.Request) (Codec, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return jsonCodec{t: t}, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, &StatusError{
+			Status: http.StatusUnsupportedMediaType,
+			Err:    fmt.Errorf("malformed content type %q", contentType),
+		}
+	}
+
+	for _, c := range t.codecs() {
+		if strings.EqualFold(c.ContentType(), mediaType) {
+			return c, nil
+		}
+	}
+
+	return nil, &StatusError{
+		Status: http.StatusUnsupportedMediaType,
+		Err:    fmt.Errorf("content type %q is not supported", mediaType),
+	}
+}
+
+// responseCodec selects the codec preferred by the Accept header of the request
+func (t *Tools) responseCodec(r *http.Request) (Codec, error) {
+	codecs := t.codecs()
+
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return codecs[0], nil
+	}
+
+	ranges := parseQualityList(strings.Join(accept, ","))
+
+	var best Codec
+	bestQuality := 0.0
+	for _, c := range codecs {
+		q := mediaQuality(ranges, c.ContentType())
+		if q > bestQuality {
+			best = c
+			bestQuality = q
+		}
+	}
+
+	if best == nil {
+		return nil, &StatusError{
+			Status: http.StatusNotAcceptable,
+			Err:    fmt.Errorf("none of the accepted media types %q are supported", strings.Join(accept, ", ")),
+		}
+	}
+
+	return best, nil
+}
+
+// qualityValue is a single element of a header such as Accept or Accept-Encoding
+type qualityValue struct {
+	Value   string
+	Quality float64
+}
+
+// parseQualityList parses a comma separated header with optional q parameters. The result is
+// sorted by quality, highest first.
+func parseQualityList(header string) []qualityValue {
+	var values []qualityValue
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			key, val, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || !strings.EqualFold(strings.TrimSpace(key), "q") {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
+		}
+
+		values = append(values, qualityValue{Value: value, Quality: q})
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Quality > values[j].Quality
+	})
+
+	return values
+}
+
+// mediaQuality returns the quality the client assigned to mediaType, taken from the most specific
+// matching media range. A result of zero means the media type is not acceptable.
+func mediaQuality(ranges []qualityValue, mediaType string) float64 {
+	mediaType = strings.ToLower(mediaType)
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	quality := 0.0
+	specificity := 0
+	for _, r := range ranges {
+		s := 0
+		switch r.Value {
+		case mediaType:
+			s = 3
+		case typ + "/*":
+			s = 2
+		case "*/*":
+			s = 1
+		}
+		if s > specificity {
+			specificity = s
+			quality = r.Quality
+		}
+	}
+
+	return quality
+}
+
+// jsonCodec is the built-in application/json codec. It decodes with the same rules as ReadJSON.
+type jsonCodec struct {
+	t *Tools
+}
+
+func (c jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (c jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return c.t.decodeJSON(r, v)
+}
+
+func (c jsonCodec) Encode(w io.Writer, v interface{}) error {
+	out, err := c.t.marshalJSON(v, nil)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// xmlCodec is the built-in XML codec
+type xmlCodec struct {
+	contentType string
+}
+
+func (c xmlCodec) ContentType() string {
+	return c.contentType
+}
+
+func (c xmlCodec) Decode(r io.Reader, v interface{}) error {
+	err := xml.NewDecoder(r).Decode(v)
+	var syntaxError *xml.SyntaxError
+	if errors.As(err, &syntaxError) {
+		return fmt.Errorf("body contains badly-formed XML (at line %d)", syntaxError.Line)
+	}
+	return err
+}
+
+func (c xmlCodec) Encode(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// formCodec is the built-in application/x-www-form-urlencoded codec
+type formCodec struct {
+	t *Tools
+}
+
+func (c formCodec) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+func (c formCodec) Decode(r io.Reader, v interface{}) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return io.EOF
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return errors.New("body contains badly-formed form data")
+	}
+
+	switch dst := v.(type) {
+	case *url.Values:
+		*dst = values
+	case *map[string][]string:
+		*dst = values
+	case *map[string]string:
+		*dst = make(map[string]string, len(values))
+		for key := range values {
+			(*dst)[key] = values.Get(key)
+		}
+	default:
+		return c.t.decodeForm(values, nil, v)
+	}
+
+	return nil
+}
+
+func (c formCodec) Encode(w io.Writer, v interface{}) error {
+	var values url.Values
+	switch src := v.(type) {
+	case url.Values:
+		values = src
+	case map[string][]string:
+		values = src
+	case map[string]string:
+		values = make(url.Values, len(src))
+		for key, value := range src {
+			values.Set(key, value)
+		}
+	default:
+		return fmt.Errorf("%T can not be encoded as form data", v)
+	}
+
+	_, err := io.WriteString(w, values.Encode())
+	return err
+}
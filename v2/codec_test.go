@@ -0,0 +1,182 @@
+package toolkit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var readBodyTests = []struct {
+	name          string
+	contentType   string
+	body          string
+	expected      string
+	errorExpected bool
+	status        int
+}{
+	{name: "json", contentType: "application/json", body: `{"foo": "bar"}`, expected: "bar"},
+	{name: "json with charset", contentType: "application/json; charset=utf-8", body: `{"foo": "bar"}`, expected: "bar"},
+	{name: "no content type", contentType: "", body: `{"foo": "bar"}`, expected: "bar"},
+	{name: "xml", contentType: "application/xml", body: `<item><foo>bar</foo></item>`, expected: "bar"},
+	{name: "bad xml", contentType: "text/xml", body: `<item><foo>bar</item>`, errorExpected: true},
+	{name: "unknown json field", contentType: "application/json", body: `{"fooo": "bar"}`, errorExpected: true},
+	{name: "empty body", contentType: "application/xml", body: ``, errorExpected: true},
+	{name: "unsupported", contentType: "text/csv", body: `foo`, errorExpected: true, status: http.StatusUnsupportedMediaType},
+	{name: "malformed content type", contentType: "/", body: `foo`, errorExpected: true, status: http.StatusUnsupportedMediaType},
+}
+
+func TestTools_ReadBody(t *testing.T) {
+	var testTools Tools
+	for _, e := range readBodyTests {
+		var decoded struct {
+			Foo string `json:"foo" xml:"foo"`
+		}
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(e.body))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		err := testTools.ReadBody(httptest.NewRecorder(), req, &decoded)
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: error expected but none received", e.name)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: error not expected but one received %s", e.name, err.Error())
+		}
+		if !e.errorExpected && decoded.Foo != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, decoded.Foo)
+		}
+
+		var statusError *StatusError
+		if e.status != 0 && (!errors.As(err, &statusError) || statusError.Status != e.status) {
+			t.Errorf("%s: expected status error %d but got %v", e.name, e.status, err)
+		}
+	}
+}
+
+func TestTools_ReadBody_Form(t *testing.T) {
+	var testTools Tools
+	req := httptest.NewRequest("POST", "/", strings.NewReader("foo=bar&alpha=beta"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var values map[string]string
+	err := testTools.ReadBody(httptest.NewRecorder(), req, &values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["foo"] != "bar" || values["alpha"] != "beta" {
+		t.Errorf("wrong form values decoded: %v", values)
+	}
+}
+
+func TestTools_ReadBody_TooLarge(t *testing.T) {
+	testTools := Tools{MaxJSONSize: 5}
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`<item><foo>bar</foo></item>`))
+	req.Header.Set("Content-Type", "application/xml")
+
+	err := testTools.ReadBody(httptest.NewRecorder(), req, &struct{}{})
+	if err == nil || err.Error() != "body must not be larger than 5 bytes" {
+		t.Errorf("expected size error but got %v", err)
+	}
+}
+
+var writeBodyTests = []struct {
+	name        string
+	accept      string
+	contentType string
+	status      int
+}{
+	{name: "no accept", accept: "", contentType: "application/json", status: http.StatusOK},
+	{name: "wildcard", accept: "*/*", contentType: "application/json", status: http.StatusOK},
+	{name: "xml", accept: "application/xml", contentType: "application/xml", status: http.StatusOK},
+	{name: "quality", accept: "application/json;q=0.5, text/xml;q=0.9", contentType: "text/xml", status: http.StatusOK},
+	{name: "type wildcard", accept: "text/*", contentType: "text/xml", status: http.StatusOK},
+	{name: "excluded", accept: "application/json;q=0, */*;q=0.1", contentType: "application/xml", status: http.StatusOK},
+	{name: "not acceptable", accept: "image/png", status: http.StatusNotAcceptable},
+}
+
+func TestTools_WriteBody(t *testing.T) {
+	var testTools Tools
+	payload := JSONResponse{Message: "foo"}
+
+	for _, e := range writeBodyTests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if e.accept != "" {
+			req.Header.Set("Accept", e.accept)
+		}
+		rr := httptest.NewRecorder()
+
+		err := testTools.WriteBody(rr, req, http.StatusOK, payload)
+		var statusError *StatusError
+		if e.status != http.StatusOK {
+			if !errors.As(err, &statusError) || statusError.Status != e.status {
+				t.Errorf("%s: expected status error %d but got %v", e.name, e.status, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: error not expected but one received %s", e.name, err.Error())
+		}
+		if got := rr.Header().Get("Content-Type"); got != e.contentType {
+			t.Errorf("%s: expected content type %s but got %s", e.name, e.contentType, got)
+		}
+		if !strings.Contains(rr.Body.String(), "foo") {
+			t.Errorf("%s: body does not contain payload: %s", e.name, rr.Body.String())
+		}
+	}
+}
+
+type upperCodec struct{}
+
+func (upperCodec) ContentType() string { return "text/plain" }
+
+func (upperCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	*(v.(*string)) = strings.ToUpper(string(b))
+	return nil
+}
+
+func (upperCodec) Encode(w io.Writer, v interface{}) error {
+	_, err := io.WriteString(w, strings.ToUpper(v.(string)))
+	return err
+}
+
+func TestTools_RegisterCodec(t *testing.T) {
+	var testTools Tools
+	testTools.RegisterCodec(upperCodec{})
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("hello"))
+	req.Header.Set("Content-Type", "text/plain")
+	var s string
+	err := testTools.ReadBody(httptest.NewRecorder(), req, &s)
+	if err != nil || s != "HELLO" {
+		t.Errorf("registered codec not used for decoding: %q %v", s, err)
+	}
+
+	req.Header.Set("Accept", "text/plain")
+	rr := httptest.NewRecorder()
+	err = testTools.WriteBody(rr, req, http.StatusOK, "world")
+	if err != nil || rr.Body.String() != "WORLD" {
+		t.Errorf("registered codec not used for encoding: %q %v", rr.Body.String(), err)
+	}
+}
+
+func TestTools_ErrorJSON_StatusError(t *testing.T) {
+	var testTools Tools
+	rr := httptest.NewRecorder()
+	err := testTools.ErrorJSON(rr, &StatusError{Status: http.StatusNotAcceptable, Err: errors.New("nope")})
+	if err != nil {
+		t.Error(err)
+	}
+	if rr.Code != http.StatusNotAcceptable {
+		t.Errorf("wrong status code; expected 406, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,279 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// limitBody replaces the body of r with a reader that transparently decompresses gzip and
+// deflate encoded bodies and fails once more than MaxJSONSize decompressed bytes have been read.
+// Applying the limit after decompression keeps small compressed bodies from expanding without
+// bounds.
+func (t *Tools) limitBody(w http.ResponseWriter, r *http.Request) error {
+	body, err := decompressBody(r)
+	if err != nil {
+		return err
+	}
+	r.Body = http.MaxBytesReader(w, body, int64(t.maxJSONSize()))
+	return nil
+}
+
+// decompressBody returns a reader for the body of r decoded according to its Content-Encoding
+func decompressBody(r *http.Request) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+
+	var reader io.ReadCloser
+	var err error
+	switch encoding {
+	case "", "identity":
+		return r.Body, nil
+
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(r.Body)
+
+	case "deflate":
+		reader, err = zlib.NewReader(r.Body)
+
+	default:
+		return nil, &StatusError{
+			Status: http.StatusUnsupportedMediaType,
+			Err:    fmt.Errorf("content encoding %q is not supported", encoding),
+		}
+	}
+
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+		return nil, fmt.Errorf("body contains badly-formed %s data", encoding)
+	}
+
+	return &decompressedBody{Reader: reader, decompressor: reader, body: r.Body}, nil
+}
+
+// decompressedBody closes both the decompressor and the original body
+type decompressedBody struct {
+	io.Reader
+	decompressor io.Closer
+	body         io.Closer
+}
+
+func (b *decompressedBody) Close() error {
+	err := b.decompressor.Close()
+	if bodyErr := b.body.Close(); err == nil {
+		err = bodyErr
+	}
+	return err
+}
+
+// Compress is a middleware that gzips responses larger than CompressionThreshold when the client
+// accepts it, and decompresses gzip and deflate encoded request bodies. Handlers using WriteJSON
+// get compressed responses by running behind it.
+func (t *Tools) Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "" {
+			body, err := decompressBody(r)
+			if err != nil {
+				_ = t.ErrorJSON(w, err)
+				return
+			}
+			r.Body = body
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+		}
+
+		w, done := t.compressResponse(w, r)
+		defer done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// compressResponse wraps w in a compressing writer when the client accepts gzip. Range requests
+// are not compressed, since the ranges refer to the uncompressed content. The returned function
+// must be called once the response is complete.
+func (t *Tools) compressResponse(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) || r.Header.Get("Range") != "" {
+		return w, func() {}
+	}
+
+	cw := t.newCompressWriter(w)
+	return cw, func() {
+		_ = cw.Close()
+	}
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r allows a gzip response
+func acceptsGzip(r *http.Request) bool {
+	quality := 0.0
+	specificity := 0
+	for _, e := range parseQualityList(strings.Join(r.Header.Values("Accept-Encoding"), ",")) {
+		s := 0
+		switch e.Value {
+		case "gzip", "x-gzip":
+			s = 2
+		case "*":
+			s = 1
+		}
+		if s > specificity {
+			specificity = s
+			quality = e.Quality
+		}
+	}
+	return quality > 0
+}
+
+// compressWriter buffers the response until it is larger than the threshold, and only then
+// starts compressing it. Smaller responses are written unchanged when the writer is closed.
+type compressWriter struct {
+	http.ResponseWriter
+	threshold   int
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+	decided     bool
+}
+
+func (t *Tools) newCompressWriter(w http.ResponseWriter) *compressWriter {
+	threshold := 1024
+	if t.CompressionThreshold != 0 {
+		threshold = t.CompressionThreshold
+	}
+
+	return &compressWriter{ResponseWriter: w, threshold: threshold, status: http.StatusOK}
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	c.status = status
+
+	// informational, body-less and partial responses are passed through straight away
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		c.decided = true
+		c.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	if c.decided {
+		if c.gz != nil {
+			return c.gz.Write(p)
+		}
+		return c.ResponseWriter.Write(p)
+	}
+
+	c.buf.Write(p)
+	if c.buf.Len() >= c.threshold {
+		err := c.start(true)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush commits to compression, so streamed responses are not held back by the buffer
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		if !c.wroteHeader {
+			c.WriteHeader(http.StatusOK)
+		}
+		_ = c.start(true)
+	}
+	if c.gz != nil {
+		_ = c.gz.Flush()
+	}
+	_ = http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+// Close writes whatever is still buffered and finishes the gzip stream
+func (c *compressWriter) Close() error {
+	if !c.decided {
+		if !c.wroteHeader {
+			c.WriteHeader(http.StatusOK)
+		}
+		err := c.start(false)
+		if err != nil {
+			return err
+		}
+	}
+	if c.gz != nil {
+		return c.gz.Close()
+	}
+	return nil
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// start sends the header and the buffered data, compressing when requested and appropriate
+func (c *compressWriter) start(compress bool) error {
+	c.decided = true
+	h := c.Header()
+
+	if h.Get("Content-Type") == "" && c.buf.Len() > 0 {
+		h.Set("Content-Type", http.DetectContentType(c.buf.Bytes()))
+	}
+
+	if compress && h.Get("Content-Encoding") == "" && compressibleType(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		c.gz = gzip.NewWriter(c.ResponseWriter)
+	}
+
+	c.ResponseWriter.WriteHeader(c.status)
+	if c.buf.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if c.gz != nil {
+		_, err = c.gz.Write(c.buf.Bytes())
+	} else {
+		_, err = c.ResponseWriter.Write(c.buf.Bytes())
+	}
+	c.buf.Reset()
+	return err
+}
+
+// compressibleType reports whether content of the given type benefits from compression.
+// Images, archives and media are usually compressed already.
+func compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-ndjson",
+		"application/x-www-form-urlencoded", "image/svg+xml", "application/wasm":
+		return true
+	}
+
+	return false
+}
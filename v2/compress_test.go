@@ -0,0 +1,287 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func gzipBytes(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz.Close()
+	return buf.Bytes()
+}
+
+func deflateBytes(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := zw.Write([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestTools_ReadJSON_Compressed(t *testing.T) {
+	bomb := `{"foo": "` + strings.Repeat("a", 1<<16) + `"}`
+
+	tests := []struct {
+		name          string
+		encoding      string
+		body          []byte
+		maxSize       int
+		errorExpected bool
+		errorMessage  string
+	}{
+		{name: "gzip", encoding: "gzip", body: gzipBytes(t, `{"foo": "bar"}`)},
+		{name: "deflate", encoding: "deflate", body: deflateBytes(t, `{"foo": "bar"}`)},
+		{name: "identity", encoding: "identity", body: []byte(`{"foo": "bar"}`)},
+		{name: "bomb", encoding: "gzip", body: gzipBytes(t, bomb), maxSize: 1 << 10, errorExpected: true, errorMessage: "body must not be larger than 1024 bytes"},
+		{name: "not gzip", encoding: "gzip", body: []byte(`{"foo": "bar"}`), errorExpected: true},
+		{name: "empty gzip", encoding: "gzip", body: []byte{}, errorExpected: true, errorMessage: "body must not be empty"},
+		{name: "unsupported", encoding: "br", body: []byte(`{"foo": "bar"}`), errorExpected: true},
+	}
+
+	for _, e := range tests {
+		testTools := Tools{MaxJSONSize: e.maxSize}
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(e.body))
+		req.Header.Set("Content-Encoding", e.encoding)
+
+		var decoded struct {
+			Foo string `json:"foo"`
+		}
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected but none received", e.name)
+			} else if e.errorMessage != "" && err.Error() != e.errorMessage {
+				t.Errorf("%s: expected error %q but got %q", e.name, e.errorMessage, err.Error())
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: error not expected but one received %s", e.name, err.Error())
+		}
+		if decoded.Foo != "bar" {
+			t.Errorf("%s: wrong value decoded %q", e.name, decoded.Foo)
+		}
+	}
+}
+
+var compressTests = []struct {
+	name           string
+	acceptEncoding string
+	body           string
+	contentType    string
+	compressed     bool
+}{
+	{name: "large json", acceptEncoding: "gzip, deflate", body: strings.Repeat("a", 2048), contentType: "application/json", compressed: true},
+	{name: "small json", acceptEncoding: "gzip", body: "abc", contentType: "application/json", compressed: false},
+	{name: "not accepted", acceptEncoding: "", body: strings.Repeat("a", 2048), contentType: "application/json", compressed: false},
+	{name: "refused", acceptEncoding: "gzip;q=0, *;q=1", body: strings.Repeat("a", 2048), contentType: "application/json", compressed: false},
+	{name: "wildcard", acceptEncoding: "*", body: strings.Repeat("a", 2048), contentType: "text/plain", compressed: true},
+	{name: "image", acceptEncoding: "gzip", body: strings.Repeat("a", 2048), contentType: "image/png", compressed: false},
+	{name: "sniffed", acceptEncoding: "gzip", body: strings.Repeat("a", 2048), contentType: "", compressed: true},
+}
+
+func TestTools_Compress(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range compressTests {
+		handler := testTools.Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if e.contentType != "" {
+				w.Header().Set("Content-Type", e.contentType)
+			}
+			// write in small chunks to cross the threshold half way
+			for i := 0; i < len(e.body); i += 100 {
+				_, _ = io.WriteString(w, e.body[i:min(i+100, len(e.body))])
+			}
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		if e.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", e.acceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		res := rr.Result()
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+
+		isCompressed := res.Header.Get("Content-Encoding") == "gzip"
+		if isCompressed != e.compressed {
+			t.Errorf("%s: expected compressed %v but got %v", e.name, e.compressed, isCompressed)
+		}
+
+		if isCompressed {
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Errorf("%s: %s", e.name, err)
+				continue
+			}
+			body, err = io.ReadAll(gz)
+			if err != nil {
+				t.Errorf("%s: %s", e.name, err)
+			}
+		}
+
+		if string(body) != e.body {
+			t.Errorf("%s: wrong body of length %d", e.name, len(body))
+		}
+		if e.acceptEncoding != "" && res.Header.Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: missing Vary header", e.name)
+		}
+	}
+}
+
+func TestTools_Compress_Range(t *testing.T) {
+	var testTools Tools
+	content := strings.Repeat("abcdefghij", 1000)
+
+	tests := []struct {
+		name        string
+		rangeHeader string
+		handler     http.HandlerFunc
+	}{
+		{
+			name:        "range request",
+			rangeHeader: "bytes=0-3000",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(content))
+			},
+		},
+		{
+			name: "partial content",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Range", "bytes 0-3000/10000")
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = io.WriteString(w, content[:3001])
+			},
+		},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/file.txt", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if e.rangeHeader != "" {
+			req.Header.Set("Range", e.rangeHeader)
+		}
+		rr := httptest.NewRecorder()
+		testTools.Compress(e.handler).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusPartialContent || rr.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s: expected an uncompressed 206 but got %d %q", e.name, rr.Code, rr.Header().Get("Content-Encoding"))
+		}
+		if rr.Body.String() != content[:3001] {
+			t.Errorf("%s: wrong body of length %d", e.name, rr.Body.Len())
+		}
+	}
+}
+
+func TestTools_Compress_WriteJSON(t *testing.T) {
+	var testTools Tools
+	payload := JSONResponse{Message: strings.Repeat("foo", 1000)}
+
+	handler := testTools.Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in JSONResponse
+		err := testTools.ReadJSON(w, r, &in)
+		if err != nil {
+			_ = testTools.ErrorJSON(w, err)
+			return
+		}
+		_ = testTools.WriteJSON(w, http.StatusCreated, in)
+	}))
+
+	in, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(gzipBytes(t, string(in))))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("wrong status code; expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("response not compressed")
+	}
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Error("wrong content type", rr.Header().Get("Content-Type"))
+	}
+
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := io.ReadAll(gz)
+	if !bytes.Equal(out, in) {
+		t.Error("wrong decompressed body")
+	}
+}
+
+func TestTools_DownloadStaticFile_Compressed(t *testing.T) {
+	testTools := Tools{CompressResponses: true}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	testTools.DownloadStaticFile(rr, req, "./testdata/pic.jpg", "puppy.jpg")
+
+	// jpeg images are compressed already
+	if rr.Header().Get("Content-Encoding") != "" {
+		t.Error("jpeg should not be compressed")
+	}
+	if rr.Header().Get("Content-Length") != "98827" {
+		t.Error("wrong content length of", rr.Header().Get("Content-Length"))
+	}
+	if rr.Body.Len() != 98827 {
+		t.Error("wrong body length of", rr.Body.Len())
+	}
+}
+
+func TestTools_WriteBody_Compressed(t *testing.T) {
+	testTools := Tools{CompressResponses: true, CompressionThreshold: 10}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/xml")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	err := testTools.WriteBody(rr, req, http.StatusOK, JSONResponse{Message: "hello world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("response not compressed")
+	}
+
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := io.ReadAll(gz)
+	if !strings.Contains(string(out), "<Message>hello world</Message>") {
+		t.Errorf("wrong body %s", out)
+	}
+}
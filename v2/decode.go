@@ -0,0 +1,215 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// scansJSON reports whether any of the limits checked by scanJSON are enabled
+func (t *Tools) scansJSON() bool {
+	return t.MaxJSONDepth > 0 || t.MaxJSONArrayLength > 0 || t.MaxJSONStringLength > 0 ||
+		t.DisallowDuplicateKeys || t.CaseSensitiveFields
+}
+
+// scanJSON walks the tokens of the first JSON value in body before it is decoded into a value
+// of type typ, enforcing MaxJSONDepth, MaxJSONArrayLength, MaxJSONStringLength,
+// DisallowDuplicateKeys and CaseSensitiveFields
+func (t *Tools) scanJSON(body []byte, typ reflect.Type) error {
+	s := jsonScanner{
+		t:      t,
+		dec:    json.NewDecoder(bytes.NewReader(body)),
+		fields: make(map[reflect.Type]map[string]reflect.Type),
+	}
+	s.dec.UseNumber()
+	return s.scanValue(typ, 0)
+}
+
+type jsonScanner struct {
+	t      *Tools
+	dec    *json.Decoder
+	fields map[reflect.Type]map[string]reflect.Type
+}
+
+// scanValue reads one value. typ is the Go type the value will be decoded into, or nil when it
+// is not known.
+func (s *jsonScanner) scanValue(typ reflect.Type, depth int) error {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return jsonDecodeError(err)
+	}
+	typ = derefType(typ)
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		depth++
+		if s.t.MaxJSONDepth > 0 && depth > s.t.MaxJSONDepth {
+			return fmt.Errorf("body must not be nested more than %d levels deep", s.t.MaxJSONDepth)
+		}
+		if tok == '{' {
+			return s.scanObject(typ, depth)
+		}
+		return s.scanArray(typ, depth)
+
+	case string:
+		return s.checkString(tok)
+	}
+
+	return nil
+}
+
+func (s *jsonScanner) scanObject(typ reflect.Type, depth int) error {
+	var seen map[string]struct{}
+	if s.t.DisallowDuplicateKeys {
+		seen = make(map[string]struct{})
+	}
+
+	for s.dec.More() {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return jsonDecodeError(err)
+		}
+		key := tok.(string)
+
+		err = s.checkString(key)
+		if err != nil {
+			return err
+		}
+		if seen != nil {
+			if _, ok := seen[key]; ok {
+				return fmt.Errorf("body contains duplicate key %q", key)
+			}
+			seen[key] = struct{}{}
+		}
+
+		childType, err := s.memberType(typ, key)
+		if err != nil {
+			return err
+		}
+		err = s.scanValue(childType, depth)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := s.dec.Token()
+	if err != nil {
+		return jsonDecodeError(err)
+	}
+	return nil
+}
+
+func (s *jsonScanner) scanArray(typ reflect.Type, depth int) error {
+	var elemType reflect.Type
+	if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+		elemType = typ.Elem()
+	}
+
+	length := 0
+	for s.dec.More() {
+		length++
+		if s.t.MaxJSONArrayLength > 0 && length > s.t.MaxJSONArrayLength {
+			return fmt.Errorf("body must not contain arrays longer than %d elements", s.t.MaxJSONArrayLength)
+		}
+
+		err := s.scanValue(elemType, depth)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := s.dec.Token()
+	if err != nil {
+		return jsonDecodeError(err)
+	}
+	return nil
+}
+
+func (s *jsonScanner) checkString(str string) error {
+	if s.t.MaxJSONStringLength > 0 && utf8.RuneCountInString(str) > s.t.MaxJSONStringLength {
+		return fmt.Errorf("body must not contain strings longer than %d characters", s.t.MaxJSONStringLength)
+	}
+	return nil
+}
+
+// memberType returns the type the member key of an object decoded into typ is stored in. With
+// CaseSensitiveFields, a key that only matches a struct field when ignoring case is rejected,
+// since encoding/json would otherwise bind it to that field.
+func (s *jsonScanner) memberType(typ reflect.Type, key string) (reflect.Type, error) {
+	if typ == nil {
+		return nil, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Map:
+		return typ.Elem(), nil
+
+	case reflect.Struct:
+		fields, ok := s.fields[typ]
+		if !ok {
+			fields = make(map[string]reflect.Type)
+			collectJSONFields(typ, fields)
+			s.fields[typ] = fields
+		}
+
+		if fieldType, ok := fields[key]; ok {
+			return fieldType, nil
+		}
+		if s.t.CaseSensitiveFields {
+			for name := range fields {
+				if strings.EqualFold(name, key) {
+					return nil, fmt.Errorf("body contains unknown key %q", key)
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// collectJSONFields maps the JSON names of the fields of a struct type, including the fields
+// promoted from embedded structs, to their types. Fields of the outer struct take precedence.
+func collectJSONFields(typ reflect.Type, fields map[string]reflect.Type) {
+	var embedded []reflect.Type
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && derefType(field.Type).Kind() == reflect.Struct {
+			embedded = append(embedded, derefType(field.Type))
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+
+	for _, e := range embedded {
+		promoted := make(map[string]reflect.Type)
+		collectJSONFields(e, promoted)
+		for name, fieldType := range promoted {
+			if _, ok := fields[name]; !ok {
+				fields[name] = fieldType
+			}
+		}
+	}
+}
+
+func derefType(typ reflect.Type) reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
+}
@@ -0,0 +1,88 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type decodeBase struct {
+	ID int `json:"id"`
+}
+
+type decodeTarget struct {
+	decodeBase
+	Name  string                 `json:"name"`
+	Tags  []string               `json:"tags"`
+	Extra map[string]interface{} `json:"extra"`
+	Child *decodeTarget          `json:"child"`
+}
+
+var decodeLimitTests = []struct {
+	name    string
+	tools   Tools
+	body    string
+	message string
+}{
+	{name: "depth within limit", tools: Tools{MaxJSONDepth: 3}, body: `{"child": {"tags": ["a"]}}`},
+	{name: "too deep", tools: Tools{MaxJSONDepth: 3}, body: `{"child": {"child": {"child": {}}}}`, message: "body must not be nested more than 3 levels deep"},
+	{name: "too deep in interface", tools: Tools{MaxJSONDepth: 2}, body: `{"extra": {"a": [1]}}`, message: "body must not be nested more than 2 levels deep"},
+	{name: "array within limit", tools: Tools{MaxJSONArrayLength: 2}, body: `{"tags": ["a", "b"]}`},
+	{name: "array too long", tools: Tools{MaxJSONArrayLength: 2}, body: `{"tags": ["a", "b", "c"]}`, message: "body must not contain arrays longer than 2 elements"},
+	{name: "string within limit", tools: Tools{MaxJSONStringLength: 4}, body: `{"name": "Józe"}`},
+	{name: "string too long", tools: Tools{MaxJSONStringLength: 4}, body: `{"name": "Johnny"}`, message: "body must not contain strings longer than 4 characters"},
+	{name: "key too long", tools: Tools{MaxJSONStringLength: 4, AllowUnknownFields: true}, body: `{"verylongkey": 1}`, message: "body must not contain strings longer than 4 characters"},
+	{name: "duplicates allowed", body: `{"name": "a", "name": "b"}`},
+	{name: "duplicate key", tools: Tools{DisallowDuplicateKeys: true}, body: `{"name": "a", "name": "b"}`, message: `body contains duplicate key "name"`},
+	{name: "duplicate nested key", tools: Tools{DisallowDuplicateKeys: true}, body: `{"name": "a", "child": {"id": 1, "id": 2}}`, message: `body contains duplicate key "id"`},
+	{name: "same key in siblings", tools: Tools{DisallowDuplicateKeys: true}, body: `{"child": {"id": 1}, "extra": {"id": 2}}`},
+	{name: "case insensitive", body: `{"NAME": "a"}`},
+	{name: "case sensitive", tools: Tools{CaseSensitiveFields: true}, body: `{"NAME": "a"}`, message: `body contains unknown key "NAME"`},
+	{name: "case sensitive promoted", tools: Tools{CaseSensitiveFields: true}, body: `{"child": {"Id": 1}}`, message: `body contains unknown key "Id"`},
+	{name: "case sensitive map keys", tools: Tools{CaseSensitiveFields: true}, body: `{"extra": {"NAME": 1}}`},
+	{name: "badly formed", tools: Tools{MaxJSONDepth: 3}, body: `{"name": }`, message: "body contains badly-formed JSON (at character 10)"},
+	{name: "empty", tools: Tools{MaxJSONDepth: 3}, body: ``, message: "body must not be empty"},
+	{name: "two values", tools: Tools{MaxJSONDepth: 3}, body: `{}{}`, message: "body contains more than one json value"},
+}
+
+func TestTools_ReadJSON_Limits(t *testing.T) {
+	for _, e := range decodeLimitTests {
+		var target decodeTarget
+		req := httptest.NewRequest("POST", "/", strings.NewReader(e.body))
+		err := e.tools.ReadJSON(httptest.NewRecorder(), req, &target)
+
+		if e.message == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", e.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != e.message {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.message, err)
+		}
+	}
+}
+
+func TestTools_ReadJSON_UseNumber(t *testing.T) {
+	body := `{"id": 9007199254740993}`
+
+	var testTools Tools
+	var data map[string]interface{}
+	err := testTools.ReadJSON(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(body)), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data["id"].(float64); !ok {
+		t.Errorf("expected float64 but got %T", data["id"])
+	}
+
+	testTools.UseNumber = true
+	err = testTools.ReadJSON(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(body)), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := data["id"].(json.Number); !ok || n.String() != "9007199254740993" {
+		t.Errorf("expected exact json.Number but got %v", data["id"])
+	}
+}
@@ -0,0 +1,331 @@
+package toolkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Dispatcher delivers JSON payloads to remote URLs in the background. Payloads are stored in an
+// Outbox before Enqueue returns, so a delivery is not lost when the process crashes; deliveries
+// still pending are picked up again by the next Run. Delivery is at least once: every attempt
+// carries the delivery ID as its Idempotency-Key so receivers can drop duplicates.
+type Dispatcher struct {
+	Outbox Outbox
+	// DeadLetter receives the deliveries that failed permanently. When it is nil they stay in
+	// Outbox with the status DeliveryDead.
+	DeadLetter Outbox
+	// Workers is the number of concurrent deliveries, 4 by default
+	Workers int
+	// PollInterval is how often Outbox is checked for deliveries that are due, 1s by default
+	PollInterval time.Duration
+	// Retry decides which failures are retried and when. MaxAttempts defaults to 10 and a
+	// Retry-After from the remote is capped at MaxBackoff.
+	Retry RetryPolicy
+	// Push configures the requests. Its Retry and IdempotencyKey are ignored.
+	Push PushOptions
+
+	t        *Tools
+	once     sync.Once
+	wake     chan struct{}
+	mu       sync.Mutex
+	inflight map[string]bool
+}
+
+// NewDispatcher returns a dispatcher storing its deliveries in outbox and sending them with
+// the settings of t. A Dispatcher literal with an Outbox works as well and uses the defaults.
+func (t *Tools) NewDispatcher(outbox Outbox) *Dispatcher {
+	return &Dispatcher{Outbox: outbox, t: t}
+}
+
+func (d *Dispatcher) tools() *Tools {
+	if d.t == nil {
+		return &Tools{}
+	}
+	return d.t
+}
+
+// wakeup returns the channel signalling new deliveries to Run
+func (d *Dispatcher) wakeup() chan struct{} {
+	d.once.Do(func() {
+		d.wake = make(chan struct{}, 1)
+	})
+	return d.wake
+}
+
+// Enqueue stores payload for delivery to url and returns the ID of the delivery
+func (d *Dispatcher) Enqueue(url string, payload interface{}, headers ...http.Header) (string, error) {
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := randomString(24, AlphabetAlphanumeric)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	delivery := &Delivery{
+		ID:          id,
+		URL:         url,
+		Payload:     out,
+		Status:      DeliveryPending,
+		NextAttempt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if len(headers) > 0 {
+		delivery.Headers = headers[0]
+	}
+
+	err = d.Outbox.Put(delivery)
+	if err != nil {
+		return "", err
+	}
+
+	d.notify()
+	return delivery.ID, nil
+}
+
+// Status returns the delivery with the given ID from the outbox or the dead-letter store
+func (d *Dispatcher) Status(id string) (*Delivery, error) {
+	delivery, err := d.Outbox.Get(id)
+	if errors.Is(err, ErrDeliveryNotFound) && d.DeadLetter != nil {
+		return d.DeadLetter.Get(id)
+	}
+	return delivery, err
+}
+
+// Redeliver moves a dead delivery back into the outbox with its attempts reset
+func (d *Dispatcher) Redeliver(id string) error {
+	delivery, err := d.Status(id)
+	if err != nil {
+		return err
+	}
+	if delivery.Status != DeliveryDead {
+		return fmt.Errorf("delivery %s is %s", id, delivery.Status)
+	}
+
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttempt = time.Now()
+	delivery.UpdatedAt = time.Now()
+	err = d.Outbox.Put(delivery)
+	if err != nil {
+		return err
+	}
+	if d.DeadLetter != nil {
+		err = d.DeadLetter.Delete(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.notify()
+	return nil
+}
+
+// Prune deletes the delivered deliveries last updated before the given time
+func (d *Dispatcher) Prune(before time.Time) error {
+	delivered, err := d.Outbox.List(DeliveryDelivered)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range delivered {
+		if delivery.UpdatedAt.Before(before) {
+			err = d.Outbox.Delete(delivery.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run delivers pending deliveries until ctx is done, then waits for the deliveries in progress
+// to stop. Deliveries interrupted by the shutdown stay pending.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	workers := d.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+	interval := d.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	jobs := make(chan *Delivery)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range jobs {
+				d.deliver(ctx, delivery)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.schedule(ctx, jobs)
+
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return nil
+		case <-d.wakeup():
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wakeup() <- struct{}{}:
+	default:
+	}
+}
+
+// schedule hands the pending deliveries that are due to the workers
+func (d *Dispatcher) schedule(ctx context.Context, jobs chan<- *Delivery) {
+	pending, err := d.Outbox.List(DeliveryPending)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, listed := range pending {
+		if listed.NextAttempt.After(time.Now()) || !d.claim(listed.ID) {
+			continue
+		}
+
+		// the list may be stale: a worker can have finished this delivery while the scheduler
+		// waited for a free worker
+		delivery, err := d.Outbox.Get(listed.ID)
+		if err != nil || delivery.Status != DeliveryPending || delivery.NextAttempt.After(time.Now()) {
+			if err != nil && !errors.Is(err, ErrDeliveryNotFound) {
+				log.Println(err)
+			}
+			d.release(listed.ID)
+			continue
+		}
+
+		select {
+		case jobs <- delivery:
+		case <-ctx.Done():
+			d.release(delivery.ID)
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) claim(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.inflight[id] {
+		return false
+	}
+	if d.inflight == nil {
+		d.inflight = make(map[string]bool)
+	}
+	d.inflight[id] = true
+	return true
+}
+
+func (d *Dispatcher) release(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inflight, id)
+}
+
+// deliver makes one attempt and records its outcome
+func (d *Dispatcher) deliver(ctx context.Context, delivery *Delivery) {
+	defer d.release(delivery.ID)
+
+	opts := d.Push
+	opts.Retry = RetryPolicy{}
+	opts.IdempotencyKey = delivery.ID
+	opts.Headers = make(http.Header)
+	for header, values := range d.Push.Headers {
+		opts.Headers[header] = values
+	}
+	for header, values := range delivery.Headers {
+		opts.Headers[header] = values
+	}
+
+	res, status, err := d.tools().PushJSONToRemoteContext(ctx, delivery.URL, delivery.Payload, opts)
+	if ctx.Err() != nil {
+		// shutting down, the delivery is attempted again by the next Run
+		if res != nil {
+			res.Body.Close()
+		}
+		return
+	}
+	if res != nil {
+		defer res.Body.Close()
+		_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+
+	if err == nil && status >= 200 && status <= 299 {
+		delivery.Status = DeliveryDelivered
+		delivery.LastError = ""
+		d.store(d.Outbox.Put(delivery))
+		return
+	}
+
+	if err != nil {
+		delivery.LastError = err.Error()
+	} else {
+		delivery.LastError = fmt.Sprintf("remote returned status %d", status)
+	}
+
+	maxAttempts := d.Retry.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+	if delivery.Attempts < maxAttempts && d.Retry.retryable(res, err) {
+		delay := d.Retry.backoff(delivery.Attempts)
+		if res != nil {
+			if after, ok := retryAfter(res); ok {
+				delay = min(after, d.Retry.maxBackoff())
+			}
+		}
+		delivery.NextAttempt = now.Add(delay)
+		d.store(d.Outbox.Put(delivery))
+		return
+	}
+
+	delivery.Status = DeliveryDead
+	if d.DeadLetter == nil {
+		d.store(d.Outbox.Put(delivery))
+		return
+	}
+	err = d.DeadLetter.Put(delivery)
+	if err == nil {
+		err = d.Outbox.Delete(delivery.ID)
+	}
+	d.store(err)
+}
+
+// store logs an error of the outbox, which the workers have no caller to return it to
+func (d *Dispatcher) store(err error) {
+	if err != nil {
+		log.Println(err)
+	}
+}
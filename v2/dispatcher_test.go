@@ -0,0 +1,252 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForStatus polls the dispatcher until the delivery has the expected status
+func waitForStatus(t *testing.T, d *Dispatcher, id string, status DeliveryStatus) *Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		delivery, err := d.Status(id)
+		if err == nil && delivery.Status == status {
+			return delivery
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("delivery %s did not become %s", id, status)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]int)
+	failures := map[string]int{"/flaky": 2}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		received[r.Header.Get("Idempotency-Key")+" "+string(body)]++
+
+		switch {
+		case r.URL.Path == "/rejected":
+			w.WriteHeader(http.StatusBadRequest)
+		case failures[r.URL.Path] > 0:
+			failures[r.URL.Path]--
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	var testTools Tools
+	dispatcher := testTools.NewDispatcher(NewMemoryOutbox())
+	dispatcher.DeadLetter = NewMemoryOutbox()
+	dispatcher.PollInterval = 10 * time.Millisecond
+	dispatcher.Retry = RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- dispatcher.Run(ctx)
+	}()
+
+	ok, err := dispatcher.Enqueue(server.URL+"/ok", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flaky, _ := dispatcher.Enqueue(server.URL+"/flaky", map[string]int{"n": 2})
+	rejected, _ := dispatcher.Enqueue(server.URL+"/rejected", map[string]int{"n": 3})
+
+	waitForStatus(t, dispatcher, ok, DeliveryDelivered)
+
+	delivery := waitForStatus(t, dispatcher, flaky, DeliveryDelivered)
+	if delivery.Attempts != 3 || delivery.LastError != "" {
+		t.Errorf("expected 3 attempts but got %+v", delivery)
+	}
+
+	delivery = waitForStatus(t, dispatcher, rejected, DeliveryDead)
+	if delivery.Attempts != 1 || delivery.LastError != "remote returned status 400" {
+		t.Errorf("expected a single failed attempt but got %+v", delivery)
+	}
+	if _, err = dispatcher.Outbox.Get(rejected); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("dead delivery still in the outbox: %v", err)
+	}
+
+	mu.Lock()
+	if received[ok+` {"n":1}`] != 1 || received[flaky+` {"n":2}`] != 3 {
+		t.Errorf("unexpected requests %v", received)
+	}
+	mu.Unlock()
+
+	// a dead delivery can be sent again
+	err = dispatcher.Redeliver(rejected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, dispatcher, rejected, DeliveryDead)
+	if err = dispatcher.Redeliver(ok); err == nil {
+		t.Error("redelivered a delivered delivery")
+	}
+
+	cancel()
+	if err = <-done; err != nil {
+		t.Error(err)
+	}
+
+	err = dispatcher.Prune(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dispatcher.Status(ok); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("delivered delivery not pruned: %v", err)
+	}
+}
+
+func TestDispatcher_Literal(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dispatcher := &Dispatcher{
+		Outbox:       NewMemoryOutbox(),
+		PollInterval: 10 * time.Millisecond,
+		Retry:        RetryPolicy{MaxAttempts: 3, MaxBackoff: 20 * time.Millisecond},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = dispatcher.Run(ctx)
+	}()
+
+	id, err := dispatcher.Enqueue(server.URL, map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the Retry-After of an hour is capped by MaxBackoff
+	delivery := waitForStatus(t, dispatcher, id, DeliveryDelivered)
+	if delivery.Attempts != 2 {
+		t.Errorf("expected 2 attempts but got %+v", delivery)
+	}
+}
+
+// staleOutbox lists the deliveries as they were before they were delivered
+type staleOutbox struct {
+	*MemoryOutbox
+}
+
+func (s staleOutbox) List(status DeliveryStatus) ([]*Delivery, error) {
+	list, err := s.MemoryOutbox.List(DeliveryDelivered)
+	for _, d := range list {
+		d.Status = DeliveryPending
+	}
+	return list, err
+}
+
+func TestDispatcher_StaleList(t *testing.T) {
+	outbox := staleOutbox{NewMemoryOutbox()}
+	_ = outbox.Put(&Delivery{ID: "a1", URL: "http://someurl", Status: DeliveryDelivered})
+
+	var testTools Tools
+	dispatcher := testTools.NewDispatcher(outbox)
+	jobs := make(chan *Delivery, 1)
+	dispatcher.schedule(context.Background(), jobs)
+
+	if len(jobs) != 0 {
+		t.Errorf("delivered delivery scheduled again: %+v", <-jobs)
+	}
+	if !dispatcher.claim("a1") {
+		t.Error("skipped delivery not released")
+	}
+}
+
+func TestFileOutbox(t *testing.T) {
+	dir := "./testdata/outbox"
+	defer os.RemoveAll(dir)
+
+	outbox, err := NewFileOutbox(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first := &Delivery{ID: "a1", URL: "http://someurl", Payload: []byte(`{"n":1}`), Status: DeliveryPending, CreatedAt: now}
+	second := &Delivery{ID: "b2", URL: "http://someurl", Payload: []byte(`{"n":2}`), Status: DeliveryPending, CreatedAt: now.Add(-time.Minute)}
+	for _, d := range []*Delivery{first, second} {
+		if err = outbox.Put(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the deliveries survive a restart
+	outbox, err = NewFileOutbox(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := outbox.List(DeliveryPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != "b2" || pending[1].ID != "a1" || string(pending[1].Payload) != `{"n":1}` {
+		t.Errorf("wrong pending deliveries %+v", pending)
+	}
+
+	first.Status = DeliveryDelivered
+	_ = outbox.Put(first)
+	got, err := outbox.Get("a1")
+	if err != nil || got.Status != DeliveryDelivered {
+		t.Errorf("delivery not updated %+v %v", got, err)
+	}
+
+	// listing the pending deliveries reads neither the delivered nor the dead ones
+	err = os.WriteFile(filepath.Join(dir, "dead", "broken.json"), []byte("{"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err = outbox.List(DeliveryPending)
+	if err != nil || len(pending) != 1 || pending[0].ID != "b2" {
+		t.Errorf("wrong pending deliveries %+v %v", pending, err)
+	}
+	if _, err = outbox.List("unknown"); err == nil {
+		t.Error("unknown status accepted")
+	}
+
+	_ = outbox.Delete("a1")
+	if _, err = outbox.Get("a1"); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("expected not found but got %v", err)
+	}
+	if err = outbox.Delete("a1"); err != nil {
+		t.Errorf("deleting a missing delivery failed: %s", err)
+	}
+
+	if err = outbox.Put(&Delivery{ID: "../escape"}); err == nil {
+		t.Error("delivery id with a path accepted")
+	}
+	if _, err = outbox.Get("../escape"); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("expected not found but got %v", err)
+	}
+}
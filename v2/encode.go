@@ -0,0 +1,90 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// JSONEncoder writes JSON values to a stream. *json.Encoder implements it.
+type JSONEncoder interface {
+	Encode(v interface{}) error
+	SetIndent(prefix, indent string)
+	SetEscapeHTML(on bool)
+}
+
+// JSONEngine creates the encoders used for JSON responses. Set Tools.JSONEngine to replace
+// encoding/json with a faster implementation.
+type JSONEngine interface {
+	NewEncoder(w io.Writer) JSONEncoder
+}
+
+// stdJSONEngine is the JSONEngine backed by encoding/json
+type stdJSONEngine struct{}
+
+func (stdJSONEngine) NewEncoder(w io.Writer) JSONEncoder {
+	return json.NewEncoder(w)
+}
+
+// marshalJSON encodes v with the configured engine, indentation and HTML escaping. r may be nil
+// when the response is not tied to a request.
+func (t *Tools) marshalJSON(v interface{}, r *http.Request) ([]byte, error) {
+	engine := t.JSONEngine
+	if engine == nil {
+		engine = stdJSONEngine{}
+	}
+
+	var buf bytes.Buffer
+	enc := engine.NewEncoder(&buf)
+	enc.SetEscapeHTML(!t.DisableHTMLEscape)
+	if indent := t.jsonIndent(r); indent != "" {
+		enc.SetIndent("", indent)
+	}
+
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// marshalCompactJSON is marshalJSON without indentation, for formats writing every value on a
+// single line
+func (t *Tools) marshalCompactJSON(v interface{}) ([]byte, error) {
+	compact := *t
+	compact.JSONIndent = ""
+	return compact.marshalJSON(v, nil)
+}
+
+// jsonIndent returns JSONIndent, or two spaces when the client asked for ?pretty=true
+func (t *Tools) jsonIndent(r *http.Request) string {
+	if t.JSONIndent != "" {
+		return t.JSONIndent
+	}
+	if r != nil {
+		if pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty")); pretty {
+			return "  "
+		}
+	}
+	return ""
+}
+
+// successPayload wraps data in SuccessEnvelope when one is configured
+func (t *Tools) successPayload(status int, data interface{}) interface{} {
+	if t.SuccessEnvelope != nil {
+		return t.SuccessEnvelope(status, data)
+	}
+	return data
+}
+
+// writeJSON is WriteJSON for the writers that have access to the request
+func (t *Tools) writeJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}, headers ...http.Header) error {
+	out, err := t.marshalJSON(t.successPayload(status, data), r)
+	if err != nil {
+		return err
+	}
+
+	return t.writeJSONBytes(w, status, out, headers...)
+}
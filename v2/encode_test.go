@@ -0,0 +1,170 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type envelope struct {
+	Data interface{} `json:"data,omitempty"`
+	Meta interface{} `json:"meta,omitempty"`
+}
+
+type envelopeError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// countingEngine wraps encoding/json and counts the encoders it creates
+type countingEngine struct {
+	encoders int
+}
+
+func (e *countingEngine) NewEncoder(w io.Writer) JSONEncoder {
+	e.encoders++
+	return json.NewEncoder(w)
+}
+
+func TestTools_WriteJSON_Options(t *testing.T) {
+	data := map[string]string{"html": "<b>"}
+
+	tests := []struct {
+		name     string
+		tools    Tools
+		expected string
+	}{
+		{name: "default", tools: Tools{}, expected: `{"html":"\u003cb\u003e"}`},
+		{name: "indent", tools: Tools{JSONIndent: "\t"}, expected: "{\n\t\"html\": \"\\u003cb\\u003e\"\n}"},
+		{name: "no html escape", tools: Tools{DisableHTMLEscape: true}, expected: `{"html":"<b>"}`},
+		{
+			name: "success envelope",
+			tools: Tools{SuccessEnvelope: func(status int, data interface{}) interface{} {
+				return envelope{Data: data, Meta: map[string]int{"status": status}}
+			}},
+			expected: `{"data":{"html":"\u003cb\u003e"},"meta":{"status":200}}`,
+		},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		err := e.tools.WriteJSON(rr, http.StatusOK, data)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_JSONEngine(t *testing.T) {
+	engine := &countingEngine{}
+	testTools := Tools{JSONEngine: engine}
+
+	err := testTools.WriteJSON(httptest.NewRecorder(), http.StatusOK, JSONResponse{Message: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = testTools.ErrorJSON(httptest.NewRecorder(), errors.New("bad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if engine.encoders != 2 {
+		t.Errorf("expected 2 encoders but got %d", engine.encoders)
+	}
+}
+
+func TestTools_ErrorJSON_Envelope(t *testing.T) {
+	testTools := Tools{
+		SuccessEnvelope: func(status int, data interface{}) interface{} {
+			return envelope{Data: data}
+		},
+		ErrorEnvelope: func(status int, err error) interface{} {
+			return envelope{Meta: envelopeError{Code: status, Message: err.Error()}}
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	err := testTools.ErrorJSON(rr, &StatusError{Status: http.StatusNotFound, Err: errors.New("not found")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"meta":{"code":404,"message":"not found"}}`
+	if rr.Code != http.StatusNotFound || rr.Body.String() != expected {
+		t.Errorf("expected %d %s but got %d %s", http.StatusNotFound, expected, rr.Code, rr.Body.String())
+	}
+}
+
+func TestTools_Pretty(t *testing.T) {
+	testTools := Tools{SuccessEnvelope: func(status int, data interface{}) interface{} {
+		return envelope{Data: data}
+	}}
+	data := map[string]interface{}{"id": 1, "name": "a"}
+
+	tests := []struct {
+		name     string
+		url      string
+		write    func(w http.ResponseWriter, r *http.Request) error
+		expected string
+	}{
+		{
+			name: "body",
+			url:  "/?pretty=true",
+			write: func(w http.ResponseWriter, r *http.Request) error {
+				return testTools.WriteBody(w, r, http.StatusOK, data)
+			},
+			expected: "{\n  \"data\": {\n    \"id\": 1,\n    \"name\": \"a\"\n  }\n}",
+		},
+		{
+			name: "not pretty",
+			url:  "/?pretty=false",
+			write: func(w http.ResponseWriter, r *http.Request) error {
+				return testTools.WriteBody(w, r, http.StatusOK, data)
+			},
+			expected: `{"data":{"id":1,"name":"a"}}`,
+		},
+		{
+			name: "fields",
+			url:  "/?fields=name&pretty=1",
+			write: func(w http.ResponseWriter, r *http.Request) error {
+				return testTools.WriteJSONFields(w, r, http.StatusOK, data)
+			},
+			expected: "{\n  \"data\": {\n    \"name\": \"a\"\n  }\n}",
+		},
+		{
+			name: "page",
+			url:  "/?pretty=true",
+			write: func(w http.ResponseWriter, r *http.Request) error {
+				return testTools.WritePage(w, r, http.StatusOK, Page{Items: []int{}, Size: 10})
+			},
+			expected: "{\n  \"data\": {\n    \"items\": [],\n    \"total\": 0,\n    \"size\": 10\n  }\n}",
+		},
+		{
+			name: "conditional",
+			url:  "/?pretty=true",
+			write: func(w http.ResponseWriter, r *http.Request) error {
+				return testTools.WriteConditionalJSON(w, r, http.StatusOK, data, CacheOptions{})
+			},
+			expected: "{\n  \"data\": {\n    \"id\": 1,\n    \"name\": \"a\"\n  }\n}",
+		},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		err := e.write(rr, httptest.NewRequest("GET", e.url, nil))
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+			continue
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package toolkit
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CacheOptions controls the validators and caching headers sent by WriteConditionalJSON
+type CacheOptions struct {
+	// Version is used as the entity tag when set. Otherwise the tag is a hash of the body.
+	Version      string
+	WeakETag     bool
+	LastModified time.Time
+	CacheControl string
+}
+
+// ETag returns an entity tag for body. Weak tags are prefixed with W/.
+func ETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	return formatETag(base64.RawURLEncoding.EncodeToString(sum[:16]), weak)
+}
+
+func formatETag(version string, weak bool) string {
+	tag := `"` + strings.ReplaceAll(version, `"`, "") + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// WriteConditionalJSON writes data like WriteJSON, but adds ETag, Last-Modified and
+// Cache-Control headers and evaluates the conditional headers of the request. When the client
+// already has the current representation a 304 Not Modified is sent without a body, and when a
+// precondition fails a 412 Precondition Failed error is written with ErrorJSON.
+func (t *Tools) WriteConditionalJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}, opts CacheOptions, headers ...http.Header) error {
+	out, err := t.marshalJSON(t.successPayload(status, data), r)
+	if err != nil {
+		return err
+	}
+
+	var etag string
+	if opts.Version != "" {
+		etag = formatETag(opts.Version, opts.WeakETag)
+	} else {
+		etag = ETag(out, opts.WeakETag)
+	}
+
+	w.Header().Set("ETag", etag)
+	if !opts.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", opts.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if opts.CacheControl != "" {
+		w.Header().Set("Cache-Control", opts.CacheControl)
+	}
+
+	if status < 200 || status > 299 {
+		return t.writeJSONBytes(w, status, out, headers...)
+	}
+
+	switch evaluatePreconditions(r, etag, opts.LastModified) {
+	case http.StatusNotModified:
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+
+	case http.StatusPreconditionFailed:
+		return t.ErrorJSON(w, errors.New("precondition failed"), http.StatusPreconditionFailed)
+	}
+
+	return t.writeJSONBytes(w, status, out, headers...)
+}
+
+// CheckPreconditions evaluates If-Match, If-None-Match and If-Unmodified-Since against the current
+// entity tag and modification time of a resource. It is meant to be called before a resource is
+// modified, and returns a 412 StatusError when the client's copy is out of date. Pass an empty
+// etag when the resource does not exist yet.
+func (t *Tools) CheckPreconditions(r *http.Request, etag string, lastModified time.Time) error {
+	if evaluatePreconditions(r, etag, lastModified) != 0 {
+		return &StatusError{
+			Status: http.StatusPreconditionFailed,
+			Err:    errors.New("precondition failed"),
+		}
+	}
+	return nil
+}
+
+// evaluatePreconditions follows the order of RFC 9110 section 13.2.2. It returns 304, 412 or 0
+// when the request should be processed normally.
+func evaluatePreconditions(r *http.Request, etag string, lastModified time.Time) int {
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !matchETag(ifMatch, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if matchETag(ifNoneMatch, etag, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(since) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// matchETag reports whether etag is in the list of entity tags of a conditional header. Strong
+// comparison requires both tags to be strong and identical; weak comparison ignores W/.
+func matchETag(header, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	for _, candidate := range parseETags(header) {
+		if strong {
+			if !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseETags splits a list of entity tags. Commas inside quoted tags do not separate elements.
+func parseETags(header string) []string {
+	var tags []string
+	for {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			return tags
+		}
+
+		start := 0
+		if strings.HasPrefix(header, "W/") {
+			start = 2
+		}
+		if len(header) <= start || header[start] != '"' {
+			return tags
+		}
+
+		end := strings.IndexByte(header[start+1:], '"')
+		if end < 0 {
+			return tags
+		}
+		end += start + 2
+		tags = append(tags, header[:end])
+		header = header[end:]
+	}
+}
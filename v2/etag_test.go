@@ -0,0 +1,133 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	a := ETag([]byte(`{"foo":"bar"}`), false)
+	b := ETag([]byte(`{"foo":"baz"}`), false)
+	if a == b {
+		t.Error("different bodies produced the same etag")
+	}
+	if a != ETag([]byte(`{"foo":"bar"}`), false) {
+		t.Error("etag is not stable")
+	}
+	if a[0] != '"' || a[len(a)-1] != '"' {
+		t.Errorf("strong etag not quoted: %s", a)
+	}
+	if w := ETag([]byte(`{"foo":"bar"}`), true); w != "W/"+a {
+		t.Errorf("wrong weak etag %s", w)
+	}
+}
+
+var conditionalTests = []struct {
+	name    string
+	method  string
+	headers map[string]string
+	opts    CacheOptions
+	status  int
+}{
+	{name: "no conditions", method: "GET", status: http.StatusOK},
+	{name: "if-none-match hit", method: "GET", headers: map[string]string{"If-None-Match": `"v1"`}, opts: CacheOptions{Version: "v1"}, status: http.StatusNotModified},
+	{name: "if-none-match list", method: "GET", headers: map[string]string{"If-None-Match": `"v0", W/"v1"`}, opts: CacheOptions{Version: "v1"}, status: http.StatusNotModified},
+	{name: "if-none-match miss", method: "GET", headers: map[string]string{"If-None-Match": `"v0"`}, opts: CacheOptions{Version: "v1"}, status: http.StatusOK},
+	{name: "if-none-match star", method: "GET", headers: map[string]string{"If-None-Match": `*`}, status: http.StatusNotModified},
+	{name: "if-none-match unsafe", method: "PUT", headers: map[string]string{"If-None-Match": `"v1"`}, opts: CacheOptions{Version: "v1"}, status: http.StatusPreconditionFailed},
+	{name: "if-match hit", method: "PUT", headers: map[string]string{"If-Match": `"v1"`}, opts: CacheOptions{Version: "v1"}, status: http.StatusOK},
+	{name: "if-match miss", method: "PUT", headers: map[string]string{"If-Match": `"v0"`}, opts: CacheOptions{Version: "v1"}, status: http.StatusPreconditionFailed},
+	{name: "if-match weak", method: "PUT", headers: map[string]string{"If-Match": `W/"v1"`}, opts: CacheOptions{Version: "v1", WeakETag: true}, status: http.StatusPreconditionFailed},
+	{name: "if-modified-since not modified", method: "GET", headers: map[string]string{"If-Modified-Since": "Sat, 01 Jan 2022 00:00:00 GMT"}, opts: CacheOptions{LastModified: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}, status: http.StatusNotModified},
+	{name: "if-modified-since modified", method: "GET", headers: map[string]string{"If-Modified-Since": "Sat, 01 Jan 2022 00:00:00 GMT"}, opts: CacheOptions{LastModified: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}, status: http.StatusOK},
+	{name: "if-none-match takes precedence", method: "GET", headers: map[string]string{"If-None-Match": `"v0"`, "If-Modified-Since": "Sat, 01 Jan 2022 00:00:00 GMT"}, opts: CacheOptions{Version: "v1", LastModified: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}, status: http.StatusOK},
+	{name: "if-unmodified-since failed", method: "PUT", headers: map[string]string{"If-Unmodified-Since": "Sat, 01 Jan 2022 00:00:00 GMT"}, opts: CacheOptions{LastModified: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}, status: http.StatusPreconditionFailed},
+}
+
+func TestTools_WriteConditionalJSON(t *testing.T) {
+	var testTools Tools
+	payload := JSONResponse{Message: "foo"}
+
+	for _, e := range conditionalTests {
+		req := httptest.NewRequest(e.method, "/", nil)
+		for key, value := range e.headers {
+			req.Header.Set(key, value)
+		}
+		e.opts.CacheControl = "private, max-age=60"
+
+		rr := httptest.NewRecorder()
+		err := testTools.WriteConditionalJSON(rr, req, http.StatusOK, payload, e.opts)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+		}
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.status, rr.Code)
+		}
+		if rr.Header().Get("ETag") == "" {
+			t.Errorf("%s: missing etag", e.name)
+		}
+		if rr.Header().Get("Cache-Control") != "private, max-age=60" {
+			t.Errorf("%s: wrong cache control %q", e.name, rr.Header().Get("Cache-Control"))
+		}
+		if e.status == http.StatusNotModified && rr.Body.Len() != 0 {
+			t.Errorf("%s: 304 response with a body", e.name)
+		}
+	}
+}
+
+func TestTools_WriteConditionalJSON_BodyHash(t *testing.T) {
+	var testTools Tools
+	payload := JSONResponse{Message: "foo"}
+
+	rr := httptest.NewRecorder()
+	err := testTools.WriteConditionalJSON(rr, httptest.NewRequest("GET", "/", nil), http.StatusOK, payload, CacheOptions{WeakETag: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag != ETag(rr.Body.Bytes(), true) {
+		t.Errorf("etag %s does not match body", etag)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	err = testTools.WriteConditionalJSON(rr, req, http.StatusOK, payload, CacheOptions{WeakETag: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 but got %d", rr.Code)
+	}
+}
+
+func TestTools_CheckPreconditions(t *testing.T) {
+	var testTools Tools
+
+	req := httptest.NewRequest("PUT", "/", nil)
+	req.Header.Set("If-Match", `"v1"`)
+	err := testTools.CheckPreconditions(req, `"v1"`, time.Time{})
+	if err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	err = testTools.CheckPreconditions(req, `"v2"`, time.Time{})
+	var statusError *StatusError
+	if !errors.As(err, &statusError) || statusError.Status != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 status error but got %v", err)
+	}
+
+	// creating a resource only if it does not exist yet
+	req = httptest.NewRequest("PUT", "/", nil)
+	req.Header.Set("If-None-Match", "*")
+	if err = testTools.CheckPreconditions(req, "", time.Time{}); err != nil {
+		t.Errorf("unexpected error for missing resource %s", err)
+	}
+	if err = testTools.CheckPreconditions(req, `"v1"`, time.Time{}); err == nil {
+		t.Error("expected error for existing resource")
+	}
+}
@@ -0,0 +1,140 @@
+package toolkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// FanOutMode decides when FanOutJSON is done
+type FanOutMode int
+
+const (
+	// FanOutAll waits for every target and fails when any of them failed
+	FanOutAll FanOutMode = iota
+	// FanOutFirstSuccess stops at the first target answering with a 2xx status, cancelling the
+	// others, and fails when none of them succeeded
+	FanOutFirstSuccess
+)
+
+// FanOutOptions configures FanOutJSON
+type FanOutOptions struct {
+	Mode FanOutMode
+	// Concurrency limits the targets called at the same time. Zero calls all of them at once.
+	Concurrency int
+	// Timeout bounds every target, including its retries and reading the response body
+	Timeout time.Duration
+	// Push configures the requests sent to every target
+	Push PushOptions
+}
+
+// FanOutResult is the outcome of the call to one target
+type FanOutResult struct {
+	URL    string
+	Status int
+	// Body is the response body, read up to MaxJSONSize
+	Body     []byte
+	Err      error
+	Duration time.Duration
+}
+
+// Succeeded reports whether the target answered with a 2xx status
+func (r FanOutResult) Succeeded() bool {
+	return r.Err == nil && r.Status >= 200 && r.Status <= 299
+}
+
+// FanOutJSON posts data as JSON to every target in parallel with PushJSONToRemoteContext. The
+// results are returned in the order of targets, along with an error when the call failed
+// according to the mode. Targets that were cancelled or never called have the context error
+// as their Err.
+func (t *Tools) FanOutJSON(ctx context.Context, targets []string, data interface{}, opts ...FanOutOptions) ([]FanOutResult, error) {
+	var opt FanOutOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = len(targets)
+	}
+	slots := make(chan struct{}, concurrency)
+
+	results := make([]FanOutResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		results[i].URL = target
+
+		wg.Add(1)
+		go func(result *FanOutResult) {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				result.Err = ctx.Err()
+				return
+			}
+			if ctx.Err() != nil {
+				result.Err = ctx.Err()
+				return
+			}
+
+			t.fanOutTarget(ctx, result, payload, opt)
+			if opt.Mode == FanOutFirstSuccess && result.Succeeded() {
+				cancel()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if !result.Succeeded() {
+			failed++
+		}
+	}
+
+	switch {
+	case opt.Mode == FanOutFirstSuccess && failed == len(results):
+		return results, fmt.Errorf("fan out failed: none of %d targets succeeded", len(results))
+	case opt.Mode == FanOutAll && failed > 0:
+		return results, fmt.Errorf("fan out failed: %d of %d targets failed", failed, len(results))
+	}
+	return results, nil
+}
+
+// fanOutTarget calls one target and records the outcome in result
+func (t *Tools) fanOutTarget(ctx context.Context, result *FanOutResult, payload []byte, opt FanOutOptions) {
+	if opt.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	res, status, err := t.PushJSONToRemoteContext(ctx, result.URL, json.RawMessage(payload), opt.Push)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	defer res.Body.Close()
+
+	result.Status = status
+	result.Body, result.Err = io.ReadAll(io.LimitReader(res.Body, int64(t.maxJSONSize())))
+}
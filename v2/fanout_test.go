@@ -0,0 +1,144 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTools_FanOutJSON(t *testing.T) {
+	var active, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// reading the body lets the server notice cancelled requests
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+		case "/fail":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+		}
+	}))
+	defer server.Close()
+
+	var tests = []struct {
+		name      string
+		targets   []string
+		opts      FanOutOptions
+		errorText string
+		statuses  []int
+		succeeded []bool
+		peak      int32
+	}{
+		{
+			name:      "all",
+			targets:   []string{"/a", "/fail", "/b", "/slow"},
+			opts:      FanOutOptions{Timeout: 50 * time.Millisecond},
+			errorText: "fan out failed: 2 of 4 targets failed",
+			statuses:  []int{200, 502, 200, 0},
+			succeeded: []bool{true, false, true, false},
+		},
+		{
+			name:      "all succeeded",
+			targets:   []string{"/a", "/b", "/c", "/d", "/e", "/f"},
+			opts:      FanOutOptions{Concurrency: 2},
+			statuses:  []int{200, 200, 200, 200, 200, 200},
+			succeeded: []bool{true, true, true, true, true, true},
+			peak:      2,
+		},
+		{
+			name:      "first success",
+			targets:   []string{"/slow", "/fail", "/a", "/slow"},
+			opts:      FanOutOptions{Mode: FanOutFirstSuccess},
+			statuses:  []int{0, 502, 200, 0},
+			succeeded: []bool{false, false, true, false},
+		},
+		{
+			name:      "first success none",
+			targets:   []string{"/fail", "/fail"},
+			opts:      FanOutOptions{Mode: FanOutFirstSuccess},
+			errorText: "fan out failed: none of 2 targets succeeded",
+			statuses:  []int{502, 502},
+			succeeded: []bool{false, false},
+		},
+	}
+
+	var testTools Tools
+	for _, e := range tests {
+		// wait for the handlers of cancelled requests to return
+		for atomic.LoadInt32(&active) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		atomic.StoreInt32(&peak, 0)
+		targets := make([]string, len(e.targets))
+		for i, target := range e.targets {
+			targets[i] = server.URL + target
+		}
+
+		start := time.Now()
+		results, err := testTools.FanOutJSON(context.Background(), targets, map[string]int{"n": 1}, e.opts)
+		if time.Since(start) > time.Second {
+			t.Errorf("%s: slow targets were not cancelled", e.name)
+		}
+		if e.errorText == "" && err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+		if e.errorText != "" && (err == nil || err.Error() != e.errorText) {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.errorText, err)
+		}
+
+		for i, result := range results {
+			if result.URL != targets[i] || result.Status != e.statuses[i] || result.Succeeded() != e.succeeded[i] {
+				t.Errorf("%s: unexpected result %d %+v", e.name, i, result)
+			}
+			if result.Status == 0 && result.Err == nil {
+				t.Errorf("%s: result %d has no error", e.name, i)
+			}
+			if result.Succeeded() && string(result.Body) != `{"path":"`+e.targets[i]+`"}` {
+				t.Errorf("%s: wrong body %s", e.name, result.Body)
+			}
+			if result.Duration <= 0 && result.Status != 0 {
+				t.Errorf("%s: result %d has no duration", e.name, i)
+			}
+		}
+		if e.peak > 0 && atomic.LoadInt32(&peak) > e.peak {
+			t.Errorf("%s: expected at most %d concurrent requests but got %d", e.name, e.peak, peak)
+		}
+	}
+}
+
+func TestTools_FanOutJSON_Cancelled(t *testing.T) {
+	var testTools Tools
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := testTools.FanOutJSON(ctx, []string{"http://someurl", "http://otherurl"}, map[string]int{"n": 1}, FanOutOptions{Concurrency: 1})
+	if err == nil {
+		t.Error("expected an error")
+	}
+	for _, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("expected the targets to be cancelled but got %+v", result)
+		}
+	}
+}
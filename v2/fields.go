@@ -0,0 +1,215 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// AllowFields restricts the fields clients may select with WriteJSONFields for values of the type
+// of v (or slices of it) to the given dot separated JSON paths. Selecting an allowed path also
+// allows all of its children. Types without an allow list may select any field. AllowFields is
+// not safe for concurrent use and should be called during setup.
+func (t *Tools) AllowFields(v interface{}, paths ...string) {
+	if t.fieldAllowLists == nil {
+		t.fieldAllowLists = make(map[reflect.Type][]string)
+	}
+	t.fieldAllowLists[fieldsType(v)] = paths
+}
+
+// WriteJSONFields writes data like WriteJSON, but prunes the output to the fields listed in the
+// fields query parameter (or FieldsParam), e.g. ?fields=id,name,owner.email. Nested objects are
+// selected with dots and selections apply to every element of arrays. Requesting a field that is
+// not in the allow list of the type results in a 400 StatusError and nothing is written.
+func (t *Tools) WriteJSONFields(w http.ResponseWriter, r *http.Request, status int, data interface{}, headers ...http.Header) error {
+	param := "fields"
+	if t.FieldsParam != "" {
+		param = t.FieldsParam
+	}
+
+	var fields []string
+	for _, value := range r.URL.Query()[param] {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return t.writeJSON(w, r, status, data, headers...)
+	}
+
+	if allowed, ok := t.fieldAllowLists[fieldsType(data)]; ok {
+		for _, field := range fields {
+			if !fieldAllowed(field, allowed) {
+				return &StatusError{
+					Status: http.StatusBadRequest,
+					Err:    fmt.Errorf("field %q can not be selected", field),
+				}
+			}
+		}
+	}
+
+	out, err := t.marshalCompactJSON(data)
+	if err != nil {
+		return err
+	}
+
+	out, err = SelectJSONFields(out, fields)
+	if err != nil {
+		return err
+	}
+
+	out, err = t.marshalJSON(t.successPayload(status, json.RawMessage(out)), r)
+	if err != nil {
+		return err
+	}
+
+	return t.writeJSONBytes(w, status, out, headers...)
+}
+
+// SelectJSONFields prunes a JSON document to the given dot separated paths. The order of the
+// remaining members is preserved.
+func SelectJSONFields(data []byte, fields []string) ([]byte, error) {
+	tree := make(fieldTree)
+	for _, field := range fields {
+		tree.add(strings.Split(field, "."))
+	}
+
+	var buf bytes.Buffer
+	err := pruneJSON(&buf, data, tree)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fieldTree holds the selected members of an object. A nil subtree selects the whole member.
+type fieldTree map[string]fieldTree
+
+func (f fieldTree) add(path []string) {
+	sub, exists := f[path[0]]
+	if len(path) == 1 {
+		f[path[0]] = nil
+		return
+	}
+	if exists && sub == nil {
+		// the whole member is selected already
+		return
+	}
+	if sub == nil {
+		sub = make(fieldTree)
+		f[path[0]] = sub
+	}
+	sub.add(path[1:])
+}
+
+func pruneJSON(buf *bytes.Buffer, data []byte, tree fieldTree) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		buf.Write(data)
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	open, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	isObject := open == json.Delim('{')
+	if isObject {
+		buf.WriteByte('{')
+	} else {
+		buf.WriteByte('[')
+	}
+
+	first := true
+	for dec.More() {
+		var sub fieldTree
+		var key string
+		if isObject {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key = tok.(string)
+		}
+
+		var raw json.RawMessage
+		err = dec.Decode(&raw)
+		if err != nil {
+			return err
+		}
+
+		if isObject {
+			var selected bool
+			sub, selected = tree[key]
+			if !selected {
+				continue
+			}
+		} else {
+			sub = tree
+		}
+
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		if isObject {
+			k, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			buf.Write(k)
+			buf.WriteByte(':')
+		}
+
+		if sub == nil {
+			buf.Write(raw)
+			continue
+		}
+		err = pruneJSON(buf, raw, sub)
+		if err != nil {
+			return err
+		}
+	}
+
+	if isObject {
+		buf.WriteByte('}')
+	} else {
+		buf.WriteByte(']')
+	}
+	return nil
+}
+
+// fieldAllowed reports whether field equals or is nested below one of the allowed paths
+func fieldAllowed(field string, allowed []string) bool {
+	for _, a := range allowed {
+		if field == a || strings.HasPrefix(field, a+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldsType returns the element type allow lists are registered for, looking through pointers,
+// slices and arrays
+func fieldsType(v interface{}) reflect.Type {
+	typ := reflect.TypeOf(v)
+	for typ != nil {
+		switch typ.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			typ = typ.Elem()
+		default:
+			return typ
+		}
+	}
+	return typ
+}
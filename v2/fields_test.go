@@ -0,0 +1,136 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var selectFieldsTests = []struct {
+	name     string
+	json     string
+	fields   []string
+	expected string
+}{
+	{name: "top level", json: `{"id":1,"name":"a","email":"b"}`, fields: []string{"id", "name"}, expected: `{"id":1,"name":"a"}`},
+	{name: "order preserved", json: `{"id":1,"name":"a","email":"b"}`, fields: []string{"email", "id"}, expected: `{"id":1,"email":"b"}`},
+	{name: "nested", json: `{"id":1,"owner":{"name":"a","email":"b"}}`, fields: []string{"owner.email"}, expected: `{"owner":{"email":"b"}}`},
+	{name: "whole nested", json: `{"id":1,"owner":{"name":"a","email":"b"}}`, fields: []string{"owner.email", "owner"}, expected: `{"owner":{"name":"a","email":"b"}}`},
+	{name: "array of objects", json: `[{"id":1,"name":"a"},{"id":2,"name":"b"}]`, fields: []string{"id"}, expected: `[{"id":1},{"id":2}]`},
+	{name: "nested array", json: `{"items":[{"id":1,"tags":["x"]},{"id":2}],"total":2}`, fields: []string{"items.id", "total"}, expected: `{"items":[{"id":1},{"id":2}],"total":2}`},
+	{name: "missing field", json: `{"id":1}`, fields: []string{"name"}, expected: `{}`},
+	{name: "scalar with subfield", json: `{"owner":"a"}`, fields: []string{"owner.email"}, expected: `{"owner":"a"}`},
+	{name: "null", json: `{"owner":null}`, fields: []string{"owner.email"}, expected: `{"owner":null}`},
+	{name: "large number", json: `{"n":12345678901234567890,"m":1}`, fields: []string{"n"}, expected: `{"n":12345678901234567890}`},
+	{name: "escaped key", json: `{"a<b":1,"c":2}`, fields: []string{"a<b"}, expected: `{"a\u003cb":1}`},
+}
+
+func TestSelectJSONFields(t *testing.T) {
+	for _, e := range selectFieldsTests {
+		out, err := SelectJSONFields([]byte(e.json), e.fields)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+			continue
+		}
+		if string(out) != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, out)
+		}
+	}
+}
+
+type fieldsOwner struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type fieldsProject struct {
+	ID     int         `json:"id"`
+	Name   string      `json:"name"`
+	Secret string      `json:"secret"`
+	Owner  fieldsOwner `json:"owner"`
+}
+
+func TestTools_WriteJSONFields(t *testing.T) {
+	var testTools Tools
+	testTools.AllowFields(fieldsProject{}, "id", "name", "owner")
+
+	projects := []fieldsProject{
+		{ID: 1, Name: "a", Secret: "s", Owner: fieldsOwner{Name: "o", Email: "o@example.com"}},
+		{ID: 2, Name: "b", Secret: "t", Owner: fieldsOwner{Name: "p", Email: "p@example.com"}},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+		status   int
+	}{
+		{name: "no selection", query: "", expected: `[{"id":1,"name":"a","secret":"s","owner":{"name":"o","email":"o@example.com"}},{"id":2,"name":"b","secret":"t","owner":{"name":"p","email":"p@example.com"}}]`},
+		{name: "selection", query: "?fields=id,owner.email", expected: `[{"id":1,"owner":{"email":"o@example.com"}},{"id":2,"owner":{"email":"p@example.com"}}]`},
+		{name: "repeated parameter", query: "?fields=id&fields=name", expected: `[{"id":1,"name":"a"},{"id":2,"name":"b"}]`},
+		{name: "not allowed", query: "?fields=id,secret", status: http.StatusBadRequest},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/"+e.query, nil)
+		err := testTools.WriteJSONFields(rr, req, http.StatusOK, &projects)
+
+		if e.status != 0 {
+			var statusError *StatusError
+			if !errors.As(err, &statusError) || statusError.Status != e.status {
+				t.Errorf("%s: expected status error %d but got %v", e.name, e.status, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, rr.Body.String())
+		}
+		if rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: wrong content type %s", e.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestTools_WriteJSONFields_Encoding(t *testing.T) {
+	data := map[string]string{"a": "<b>", "c": "d"}
+
+	for _, e := range []struct {
+		name     string
+		tools    Tools
+		expected string
+	}{
+		{name: "escaped", expected: `{"a":"\u003cb\u003e"}`},
+		{name: "no html escape", tools: Tools{DisableHTMLEscape: true}, expected: `{"a":"<b>"}`},
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/?fields=a", nil)
+		err := e.tools.WriteJSONFields(rr, req, http.StatusOK, data)
+		if err != nil {
+			t.Fatalf("%s: %s", e.name, err)
+		}
+		if strings.TrimSpace(rr.Body.String()) != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_WriteJSONFields_Param(t *testing.T) {
+	testTools := Tools{FieldsParam: "only"}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/?only=message&fields=error", nil)
+	err := testTools.WriteJSONFields(rr, req, http.StatusOK, JSONResponse{Message: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Body.String() != `{"message":"foo"}` {
+		t.Errorf("wrong body %s", rr.Body.String())
+	}
+}
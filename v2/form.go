@@ -0,0 +1,275 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FormFile is an uploaded file bound by ReadForm. Its type has been checked against
+// AllowedFileTypes and its size against MaxFileSize, like UploadFiles does.
+type FormFile struct {
+	*multipart.FileHeader
+	ContentType string
+	t           *Tools
+}
+
+// Save copies the file into uploadDir, giving it a random name unless rename is false
+func (f *FormFile) Save(uploadDir string, rename ...bool) (*UploadedFile, error) {
+	renameFile := true
+	if len(rename) > 0 {
+		renameFile = rename[0]
+	}
+
+	err := f.t.CreateDirIfNotExists(uploadDir)
+	if err != nil {
+		return nil, err
+	}
+	return f.t.saveUploadedFile(f.FileHeader, uploadDir, renameFile)
+}
+
+var (
+	formFileType      = reflect.TypeOf((*FormFile)(nil))
+	formFileSliceType = reflect.TypeOf([]*FormFile(nil))
+)
+
+// ReadForm decodes an application/x-www-form-urlencoded or multipart/form-data request into the
+// struct pointed to by dst, using the form struct tag for the field names. Nested structs use
+// dotted keys (address.city) and slices of structs use indexed keys (items[0].name). Uploaded
+// files are bound to *FormFile and []*FormFile fields. URL-encoded bodies are limited to
+// MaxJSONSize and multipart bodies to MaxFileSize.
+func (t *Tools) ReadForm(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+
+	var files map[string][]*multipart.FileHeader
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		r.Body = http.MaxBytesReader(w, r.Body, int64(t.maxJSONSize()))
+		err = r.ParseForm()
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+			}
+			return errors.New("body contains badly-formed form data")
+		}
+
+	case "multipart/form-data":
+		maxSize := t.maxFileSize()
+		r.Body = http.MaxBytesReader(w, r.Body, int64(maxSize))
+		err = r.ParseMultipartForm(int64(maxSize))
+		if err != nil {
+			return errors.New("uploaded file is too big")
+		}
+		files = r.MultipartForm.File
+
+	default:
+		return &StatusError{
+			Status: http.StatusUnsupportedMediaType,
+			Err:    errors.New("content type must be a form"),
+		}
+	}
+
+	return t.decodeForm(r.PostForm, files, dst)
+}
+
+// maxFileSize returns the maximum size of an uploaded file in bytes
+func (t *Tools) maxFileSize() int {
+	if t.MaxFileSize == 0 {
+		return 1 << 30 // 1 GB
+	}
+	return t.MaxFileSize
+}
+
+// decodeForm binds form values and files into dst, which must point to a struct
+func (t *Tools) decodeForm(values url.Values, files map[string][]*multipart.FileHeader, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("form target must be a non-nil pointer to a struct, got %T", dst)
+	}
+
+	d := formDecoder{t: t, values: values, files: files}
+	return d.bindStruct(rv.Elem(), "", "")
+}
+
+type formDecoder struct {
+	t      *Tools
+	values url.Values
+	files  map[string][]*multipart.FileHeader
+}
+
+func (d *formDecoder) bindStruct(v reflect.Value, keyPrefix, pathPrefix string) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("form")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		key := keyPrefix + name
+		path := pathPrefix + fieldName(field)
+		fv := v.Field(i)
+
+		var found bool
+		var err error
+		switch {
+		case field.Type == formFileType || field.Type == formFileSliceType:
+			found, err = d.bindFiles(fv, key)
+
+		case isNestedStruct(field.Type):
+			err = d.bindStruct(fv, key+".", path+".")
+			found = true
+
+		case field.Type.Kind() == reflect.Slice && isNestedStruct(field.Type.Elem()):
+			found, err = d.bindStructSlice(fv, key, path)
+
+		default:
+			values := d.values[key]
+			if field.Type.Kind() == reflect.Slice {
+				values = append(values, d.indexedValues(key)...)
+			}
+			if len(values) > 0 {
+				found = true
+				if setField(fv, values) != nil {
+					err = fmt.Errorf("form field %q contains incorrect type for field %q", key, path)
+				}
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+		if !found && opts == "required" {
+			return fmt.Errorf("form field %q must not be empty", key)
+		}
+	}
+	return nil
+}
+
+// bindFiles checks the files uploaded as key and stores them in a *FormFile or []*FormFile
+func (d *formDecoder) bindFiles(v reflect.Value, key string) (bool, error) {
+	headers := d.files[key]
+	if len(headers) == 0 {
+		return false, nil
+	}
+
+	var formFiles []*FormFile
+	for _, header := range headers {
+		if header.Size > int64(d.t.maxFileSize()) {
+			return true, errors.New("uploaded file is too big")
+		}
+
+		f, err := header.Open()
+		if err != nil {
+			return true, err
+		}
+		contentType, err := d.t.checkFileType(f)
+		f.Close()
+		if err != nil {
+			return true, err
+		}
+
+		formFiles = append(formFiles, &FormFile{FileHeader: header, ContentType: contentType, t: d.t})
+	}
+
+	if v.Type() == formFileType {
+		v.Set(reflect.ValueOf(formFiles[0]))
+	} else {
+		v.Set(reflect.ValueOf(formFiles))
+	}
+	return true, nil
+}
+
+// bindStructSlice binds keys of the form key[i].field into a slice of structs
+func (d *formDecoder) bindStructSlice(v reflect.Value, key, path string) (bool, error) {
+	indices, err := d.indices(key)
+	if err != nil || len(indices) == 0 {
+		return false, err
+	}
+
+	length := 0
+	for i := range indices {
+		length = max(length, i+1)
+	}
+
+	slice := reflect.MakeSlice(v.Type(), length, length)
+	for i := range indices {
+		err = d.bindStruct(slice.Index(i), fmt.Sprintf("%s[%d].", key, i), fmt.Sprintf("%s[%d].", path, i))
+		if err != nil {
+			return true, err
+		}
+	}
+	v.Set(slice)
+	return true, nil
+}
+
+// indexedValues returns the values of key[0], key[1], ... in index order
+func (d *formDecoder) indexedValues(key string) []string {
+	indices, err := d.indices(key)
+	if err != nil {
+		return nil
+	}
+
+	var values []string
+	for i := 0; len(indices) > 0; i++ {
+		if _, ok := indices[i]; ok {
+			values = append(values, d.values[fmt.Sprintf("%s[%d]", key, i)]...)
+			delete(indices, i)
+		}
+	}
+	return values
+}
+
+// indices collects the indices used with key in the form. Indices can not exceed the number of
+// keys in the form, which keeps a single sparse key from allocating a huge slice.
+func (d *formDecoder) indices(key string) (map[int]struct{}, error) {
+	indices := make(map[int]struct{})
+	limit := len(d.values) + len(d.files)
+
+	collect := func(k string) error {
+		rest, ok := strings.CutPrefix(k, key+"[")
+		if !ok {
+			return nil
+		}
+		index, _, ok := strings.Cut(rest, "]")
+		if !ok {
+			return nil
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 || i >= limit {
+			return fmt.Errorf("form field %q has an invalid index", k)
+		}
+		indices[i] = struct{}{}
+		return nil
+	}
+
+	for k := range d.values {
+		if err := collect(k); err != nil {
+			return nil, err
+		}
+	}
+	for k := range d.files {
+		if err := collect(k); err != nil {
+			return nil, err
+		}
+	}
+	return indices, nil
+}
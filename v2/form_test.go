@@ -0,0 +1,211 @@
+package toolkit
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type formAddress struct {
+	City string `form:"city"`
+	Zip  string `form:"zip"`
+}
+
+type formItem struct {
+	Name string `form:"name"`
+	Qty  int    `form:"qty"`
+}
+
+type formOrder struct {
+	Name     string      `form:"name,required"`
+	Age      int         `form:"age"`
+	Agree    bool        `form:"agree"`
+	Tags     []string    `form:"tags"`
+	Address  formAddress `form:"address"`
+	Items    []formItem  `form:"items"`
+	Avatar   *FormFile   `form:"avatar"`
+	Pictures []*FormFile `form:"pictures"`
+}
+
+func TestTools_ReadForm_URLEncoded(t *testing.T) {
+	var testTools Tools
+
+	values := url.Values{}
+	values.Set("name", "John")
+	values.Set("age", "42")
+	values.Set("agree", "true")
+	values.Add("tags", "a")
+	values.Set("tags[0]", "b")
+	values.Set("tags[1]", "c")
+	values.Set("address.city", "Dhaka")
+	values.Set("items[0].name", "apple")
+	values.Set("items[0].qty", "3")
+	values.Set("items[1].name", "pear")
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var order formOrder
+	err := testTools.ReadForm(httptest.NewRecorder(), req, &order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order.Name != "John" || order.Age != 42 || !order.Agree {
+		t.Errorf("wrong scalar values %+v", order)
+	}
+	if !slices.Equal(order.Tags, []string{"a", "b", "c"}) {
+		t.Errorf("wrong tags %v", order.Tags)
+	}
+	if order.Address.City != "Dhaka" || order.Address.Zip != "" {
+		t.Errorf("wrong address %+v", order.Address)
+	}
+	if !slices.Equal(order.Items, []formItem{{Name: "apple", Qty: 3}, {Name: "pear"}}) {
+		t.Errorf("wrong items %+v", order.Items)
+	}
+	if order.Avatar != nil || order.Pictures != nil {
+		t.Error("files bound from a url-encoded form")
+	}
+}
+
+var readFormErrorTests = []struct {
+	name        string
+	body        string
+	contentType string
+	message     string
+}{
+	{name: "bad int", body: "name=a&age=old", message: `form field "age" contains incorrect type for field "Age"`},
+	{name: "bad nested int", body: "name=a&items[0].qty=many", message: `form field "items[0].qty" contains incorrect type for field "Items[0].Qty"`},
+	{name: "required", body: "age=1", message: `form field "name" must not be empty`},
+	{name: "sparse index", body: "name=a&items[500].name=x", message: `form field "items[500].name" has an invalid index`},
+	{name: "not a form", body: `{"name":"a"}`, contentType: "application/json", message: "content type must be a form"},
+}
+
+func TestTools_ReadForm_Errors(t *testing.T) {
+	var testTools Tools
+	for _, e := range readFormErrorTests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(e.body))
+		if e.contentType == "" {
+			e.contentType = "application/x-www-form-urlencoded"
+		}
+		req.Header.Set("Content-Type", e.contentType)
+
+		var order formOrder
+		err := testTools.ReadForm(httptest.NewRecorder(), req, &order)
+		if err == nil {
+			t.Errorf("%s: error expected but none received", e.name)
+			continue
+		}
+		if err.Error() != e.message {
+			t.Errorf("%s: expected error %q but got %q", e.name, e.message, err.Error())
+		}
+	}
+}
+
+func multipartOrder(t *testing.T) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	_ = writer.WriteField("name", "John")
+	_ = writer.WriteField("address.zip", "1207")
+
+	img, err := os.ReadFile("./testdata/img.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"avatar", "pictures", "pictures"} {
+		part, err := writer.CreateFormFile(field, "img.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = part.Write(img)
+	}
+
+	writer.Close()
+	return &body, writer.FormDataContentType()
+}
+
+func TestTools_ReadForm_Multipart(t *testing.T) {
+	testTools := Tools{AllowedFileTypes: []string{"image/png"}}
+
+	body, contentType := multipartOrder(t)
+	req := httptest.NewRequest("POST", "/", body)
+	req.Header.Set("Content-Type", contentType)
+
+	var order formOrder
+	err := testTools.ReadForm(httptest.NewRecorder(), req, &order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order.Name != "John" || order.Address.Zip != "1207" {
+		t.Errorf("wrong values %+v", order)
+	}
+	if order.Avatar == nil || order.Avatar.ContentType != "image/png" || order.Avatar.Filename != "img.png" {
+		t.Fatalf("wrong avatar %+v", order.Avatar)
+	}
+	if len(order.Pictures) != 2 {
+		t.Errorf("expected 2 pictures but got %d", len(order.Pictures))
+	}
+
+	uploaded, err := order.Avatar.Save("./testdata/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.Open("./testdata/uploads/" + uploaded.NewFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := io.Copy(io.Discard, saved)
+	saved.Close()
+	if n != order.Avatar.Size || uploaded.FileSize != n {
+		t.Errorf("wrong saved size %d", n)
+	}
+
+	// cleanup
+	_ = os.Remove("./testdata/uploads/" + uploaded.NewFileName)
+}
+
+func TestTools_ReadForm_FileChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		tools   Tools
+		message string
+	}{
+		{name: "type not permitted", tools: Tools{AllowedFileTypes: []string{"image/jpeg"}}, message: "uploaded file type is not permitted"},
+		{name: "too big", tools: Tools{MaxFileSize: 100}, message: "uploaded file is too big"},
+	}
+
+	for _, e := range tests {
+		body, contentType := multipartOrder(t)
+		req := httptest.NewRequest("POST", "/", body)
+		req.Header.Set("Content-Type", contentType)
+
+		var order formOrder
+		err := e.tools.ReadForm(httptest.NewRecorder(), req, &order)
+		if err == nil || err.Error() != e.message {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.message, err)
+		}
+	}
+}
+
+func TestTools_ReadBody_FormStruct(t *testing.T) {
+	var testTools Tools
+	req := httptest.NewRequest("POST", "/", strings.NewReader("name=John&items[0].qty=2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var order formOrder
+	err := testTools.ReadBody(httptest.NewRecorder(), req, &order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Name != "John" || len(order.Items) != 1 || order.Items[0].Qty != 2 {
+		t.Errorf("wrong values %+v", order)
+	}
+}
@@ -0,0 +1,235 @@
+package toolkit
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idClock returns the time embedded in UUIDv7s and ULIDs, replaced in tests
+var idClock = time.Now
+
+// UUID is a universally unique identifier as defined by RFC 9562. Its text form is the
+// canonical xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, which is also used for JSON.
+type UUID [16]byte
+
+// NewUUIDv4 returns a random UUID
+func NewUUIDv4() (UUID, error) {
+	var u UUID
+	err := randomBytes(u[:])
+	if err != nil {
+		return UUID{}, err
+	}
+	u.setVersion(4)
+	return u, nil
+}
+
+// NewUUIDv7 returns a UUID starting with the current Unix time in milliseconds followed by
+// random bits, so UUIDs created in different milliseconds sort in creation order
+func NewUUIDv7() (UUID, error) {
+	var u UUID
+	err := randomBytes(u[6:])
+	if err != nil {
+		return UUID{}, err
+	}
+	putMillis(u[:6], uint64(idClock().UnixMilli()))
+	u.setVersion(7)
+	return u, nil
+}
+
+// ParseUUID parses a UUID in its canonical form, in upper or lower case
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid uuid %q", s)
+	}
+	_, err := hex.Decode(u[:], []byte(s[0:8]+s[9:13]+s[14:18]+s[19:23]+s[24:]))
+	if err != nil {
+		return UUID{}, fmt.Errorf("invalid uuid %q", s)
+	}
+	return u, nil
+}
+
+func (u *UUID) setVersion(version byte) {
+	u[6] = u[6]&0x0f | version<<4
+	// RFC 9562 variant
+	u[8] = u[8]&0x3f | 0x80
+}
+
+// Version returns the version of the UUID, 4 or 7 for the UUIDs created by this package
+func (u UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+// Time returns the creation time of a version 7 UUID and the zero time for other versions
+func (u UUID) Time() time.Time {
+	if u.Version() != 7 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(millis(u[:6])))
+}
+
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// ULID is a universally unique lexicographically sortable identifier: a 48 bit Unix time in
+// milliseconds followed by 80 random bits, written as 26 characters of Crockford's base32.
+type ULID [16]byte
+
+// ulidState keeps the last ULID so that ULIDs created within the same millisecond increase
+var ulidState struct {
+	mu   sync.Mutex
+	last ULID
+}
+
+// NewULID returns a ULID for the current time. ULIDs created in the same millisecond, or while
+// the clock goes backwards, reuse the time of the previous ULID and increment its random part,
+// so every ULID sorts after the ones created before it in this process.
+func NewULID() (ULID, error) {
+	ulidState.mu.Lock()
+	defer ulidState.mu.Unlock()
+
+	now := uint64(idClock().UnixMilli())
+	last := &ulidState.last
+
+	if last.millis() != 0 && now <= last.millis() {
+		// increment the 80 bit random part
+		for i := 15; i >= 6; i-- {
+			last[i]++
+			if last[i] != 0 {
+				return *last, nil
+			}
+		}
+		return ULID{}, errors.New("ulid random part overflowed within a millisecond")
+	}
+
+	var u ULID
+	err := randomBytes(u[6:])
+	if err != nil {
+		return ULID{}, err
+	}
+	putMillis(u[:6], now)
+	*last = u
+	return u, nil
+}
+
+// ParseULID parses the text form of a ULID, in upper or lower case
+func ParseULID(s string) (ULID, error) {
+	if len(s) != 26 || s[0] > '7' {
+		return ULID{}, fmt.Errorf("invalid ulid %q", s)
+	}
+
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		v := strings.IndexByte(AlphabetCrockford, upper(s[i]))
+		if v < 0 {
+			return ULID{}, fmt.Errorf("invalid ulid %q", s)
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+
+	var u ULID
+	binary.BigEndian.PutUint64(u[:8], hi)
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return u, nil
+}
+
+func (u ULID) millis() uint64 {
+	return millis(u[:6])
+}
+
+// Time returns the creation time of the ULID
+func (u ULID) Time() time.Time {
+	return time.UnixMilli(int64(u.millis()))
+}
+
+func (u ULID) String() string {
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+
+	var buf [26]byte
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = AlphabetCrockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+func (u ULID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *ULID) UnmarshalText(text []byte) error {
+	parsed, err := ParseULID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// NewNanoID returns a NanoID: size random characters, 21 by default, from alphabet, which
+// defaults to the URL safe alphabet
+func NewNanoID(size int, alphabet ...string) (string, error) {
+	if size <= 0 {
+		size = 21
+	}
+	symbols := AlphabetURLSafe
+	if len(alphabet) > 0 {
+		symbols = alphabet[0]
+	}
+	return randomString(size, symbols)
+}
+
+// putMillis writes the low 48 bits of ms to b big endian
+func putMillis(b []byte, ms uint64) {
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+func millis(b []byte) uint64 {
+	var ms uint64
+	for _, c := range b[:6] {
+		ms = ms<<8 | uint64(c)
+	}
+	return ms
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
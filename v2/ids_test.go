@@ -0,0 +1,225 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[47][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestUUID(t *testing.T) {
+	defer func() { idClock = time.Now }()
+	now := time.UnixMilli(1700000000123)
+	idClock = func() time.Time { return now }
+
+	var tests = []struct {
+		name    string
+		new     func() (UUID, error)
+		version int
+		time    time.Time
+	}{
+		{name: "v4", new: NewUUIDv4, version: 4},
+		{name: "v7", new: NewUUIDv7, version: 7, time: now},
+	}
+
+	for _, e := range tests {
+		u, err := e.new()
+		if err != nil {
+			t.Fatalf("%s: %s", e.name, err)
+		}
+		other, _ := e.new()
+		if u == other {
+			t.Errorf("%s: generated the same uuid twice", e.name)
+		}
+		if !uuidPattern.MatchString(u.String()) {
+			t.Errorf("%s: invalid uuid %s", e.name, u)
+		}
+		if u.Version() != e.version || !u.Time().Equal(e.time) {
+			t.Errorf("%s: expected version %d and time %s but got %d and %s", e.name, e.version, e.time, u.Version(), u.Time())
+		}
+
+		parsed, err := ParseUUID(strings.ToUpper(u.String()))
+		if err != nil || parsed != u {
+			t.Errorf("%s: parsed %s as %s %v", e.name, u, parsed, err)
+		}
+	}
+
+	// v7 uuids sort by creation time
+	first, _ := NewUUIDv7()
+	now = now.Add(time.Millisecond)
+	second, _ := NewUUIDv7()
+	if bytes.Compare(first[:], second[:]) >= 0 || first.String() >= second.String() {
+		t.Errorf("expected %s before %s", first, second)
+	}
+	if !strings.HasPrefix(first.String(), "018bcfe5-687b-7") {
+		t.Errorf("unexpected time prefix in %s", first)
+	}
+
+	for _, s := range []string{"", "018bcfe5687b7000800000000000000000", "018bcfe5-687b-7000-8000-00000000000g", "018bcfe5-687b-7000-8000+000000000000"} {
+		if _, err := ParseUUID(s); err == nil {
+			t.Errorf("parsed invalid uuid %q", s)
+		}
+	}
+
+	var payload struct {
+		ID UUID `json:"id"`
+	}
+	err := json.Unmarshal([]byte(`{"id":"018bcfe5-687b-7000-8000-000000000001"}`), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := json.Marshal(payload)
+	if string(out) != `{"id":"018bcfe5-687b-7000-8000-000000000001"}` {
+		t.Errorf("unexpected json %s", out)
+	}
+}
+
+func TestULID(t *testing.T) {
+	defer func() {
+		idClock = time.Now
+		ulidState.last = ULID{}
+	}()
+	now := time.UnixMilli(1700000000123)
+	idClock = func() time.Time { return now }
+
+	// ulids of the same millisecond and after the clock went back still increase
+	var ids []string
+	for i := 0; i < 100; i++ {
+		if i == 50 {
+			now = now.Add(-time.Second)
+		}
+		u, err := NewULID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !u.Time().Equal(time.UnixMilli(1700000000123)) {
+			t.Errorf("unexpected time %s", u.Time())
+		}
+		ids = append(ids, u.String())
+	}
+	if !sort.StringsAreSorted(ids) || ids[0] == ids[1] {
+		t.Errorf("ulids are not increasing: %v", ids[:3])
+	}
+
+	now = now.Add(2 * time.Second)
+	u, _ := NewULID()
+	if !u.Time().Equal(now) || u.String() <= ids[99] {
+		t.Errorf("expected a later ulid than %s but got %s", ids[99], u)
+	}
+
+	// the random part overflows
+	for i := 6; i < 16; i++ {
+		ulidState.last[i] = 0xff
+	}
+	if _, err := NewULID(); err == nil {
+		t.Error("expected an overflow error")
+	}
+
+	var tests = []struct {
+		text  string
+		ulid  ULID
+		time  int64
+		valid bool
+	}{
+		{text: "00000000000000000000000000", valid: true},
+		{text: "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", ulid: ULID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, time: 1<<48 - 1, valid: true},
+		{text: "01ARZ3NDEKTSV4RRFFQ69G5FAV", time: 1469922850259, valid: true},
+		{text: "8ZZZZZZZZZZZZZZZZZZZZZZZZZ"},
+		{text: "01AN4Z07BY79KA1307SR9X4MU3"},
+		{text: "01AN4Z07BY79KA1307SR9X4MV"},
+	}
+	for _, e := range tests {
+		parsed, err := ParseULID(strings.ToLower(e.text))
+		if !e.valid {
+			if err == nil {
+				t.Errorf("parsed invalid ulid %s", e.text)
+			}
+			continue
+		}
+		if err != nil || parsed.millis() != uint64(e.time) || (e.ulid != ULID{} && parsed != e.ulid) {
+			t.Errorf("parsed %s as %v %v", e.text, parsed, err)
+		}
+		if parsed.String() != e.text {
+			t.Errorf("expected %s but got %s", e.text, parsed)
+		}
+	}
+}
+
+func TestNewNanoID(t *testing.T) {
+	id, err := NewNanoID(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 21 || strings.Trim(id, AlphabetURLSafe) != "" {
+		t.Errorf("invalid nano id %s", id)
+	}
+
+	id, _ = NewNanoID(10, AlphabetHex)
+	if len(id) != 10 || strings.Trim(id, AlphabetHex) != "" {
+		t.Errorf("invalid nano id %s", id)
+	}
+
+	if _, err = NewNanoID(10, "aa"); err == nil {
+		t.Error("expected an invalid alphabet error")
+	}
+}
+
+func TestTools_FileNameGenerator(t *testing.T) {
+	var tests = []struct {
+		name          string
+		generator     func() (string, error)
+		pattern       string
+		errorExpected bool
+	}{
+		{name: "default", pattern: `^[a-zA-Z0-9]{32}\.png$`},
+		{name: "uuid", generator: func() (string, error) {
+			u, err := NewUUIDv7()
+			return u.String(), err
+		}, pattern: `^[0-9a-f-]{36}\.png$`},
+		{name: "path", generator: func() (string, error) { return "../escape", nil }, errorExpected: true},
+	}
+
+	img, err := os.ReadFile("./testdata/img.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range tests {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("file", "img.png")
+		_, _ = io.Copy(part, bytes.NewReader(img))
+		writer.Close()
+
+		request := httptest.NewRequest("POST", "/", &body)
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+
+		testTools := Tools{FileNameGenerator: e.generator}
+		dir := t.TempDir()
+		files, err := testTools.UploadFiles(request, dir)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: expected an error", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", e.name, err)
+		}
+		if !regexp.MustCompile(e.pattern).MatchString(files[0].NewFileName) {
+			t.Errorf("%s: unexpected file name %s", e.name, files[0].NewFileName)
+		}
+		if _, err = os.Stat(dir + "/" + files[0].NewFileName); err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redactedValue = "[REDACTED]"
+	// maxLoggedCapture is the most of a body buffered for logging; larger JSON bodies are left
+	// out of the log when fields have to be redacted
+	maxLoggedCapture = 1 << 20
+)
+
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// LoggingTransport is an http.RoundTripper that logs every request with its method, URL,
+// headers, body, response status, latency and response body through log/slog. Use it as the
+// Transport of the http.Client given to PushJSONToRemoteContext, a JSONClient or a Dispatcher.
+// The entry is written when the response body is read to the end or closed, or when the
+// request fails.
+type LoggingTransport struct {
+	// Base makes the requests, http.DefaultTransport by default
+	Base http.RoundTripper
+	// Logger receives the entries, slog.Default() by default
+	Logger *slog.Logger
+	// Level of the entries, slog.LevelInfo by default
+	Level slog.Level
+	// MaxBodySize is the number of bytes of every body logged, 1024 by default. A negative
+	// value leaves bodies out of the log.
+	MaxBodySize int
+	// RedactHeaders are replaced by [REDACTED] in the log, in addition to Authorization,
+	// Proxy-Authorization, Cookie and Set-Cookie
+	RedactHeaders []string
+	// RedactFields are dotted paths of JSON body fields replaced by [REDACTED] as described for
+	// RedactJSON, such as "password" or "card.number"
+	RedactFields []string
+}
+
+func (l *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := l.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	logger := l.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx := req.Context()
+	if !logger.Enabled(ctx, l.Level) {
+		return base.RoundTrip(req)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Redacted()),
+		slog.Any("request_headers", l.headers(req.Header)),
+	}
+
+	if l.MaxBodySize >= 0 && req.Body != nil && req.Body != http.NoBody {
+		body, clone, err := captureRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		req = clone
+		attrs = append(attrs, slog.String("request_body", l.body(body, req.Header)))
+	}
+
+	start := time.Now()
+	res, err := base.RoundTrip(req)
+	duration := time.Since(start)
+	if err != nil {
+		attrs = append(attrs, slog.Duration("duration", duration), slog.String("error", err.Error()))
+		logger.LogAttrs(ctx, l.Level, "outbound request", attrs...)
+		return nil, err
+	}
+
+	attrs = append(attrs,
+		slog.Int("status", res.StatusCode),
+		slog.Duration("duration", duration),
+		slog.Any("response_headers", l.headers(res.Header)),
+	)
+	res.Body = &loggingBody{
+		ReadCloser: res.Body,
+		log: func(body []byte) {
+			if l.MaxBodySize >= 0 {
+				attrs = append(attrs, slog.String("response_body", l.body(body, res.Header)))
+			}
+			logger.LogAttrs(ctx, l.Level, "outbound request", attrs...)
+		},
+	}
+	return res, nil
+}
+
+// captureRequestBody returns the start of the request body along with the request to send,
+// whose body is replaced when it had to be read
+func captureRequestBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer body.Close()
+		data, err := io.ReadAll(io.LimitReader(body, maxLoggedCapture))
+		return data, req, err
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(data))
+	return data[:min(len(data), maxLoggedCapture)], clone, nil
+}
+
+// headers returns a copy of header with the redacted headers replaced
+func (l *LoggingTransport) headers(header http.Header) http.Header {
+	logged := header.Clone()
+	if logged == nil {
+		return http.Header{}
+	}
+	for _, name := range append(defaultRedactedHeaders, l.RedactHeaders...) {
+		if values, ok := logged[http.CanonicalHeaderKey(name)]; ok {
+			for i := range values {
+				values[i] = redactedValue
+			}
+		}
+	}
+	return logged
+}
+
+// body returns the logged form of a body: JSON bodies with their fields redacted, truncated to
+// MaxBodySize. JSON bodies that cannot be decoded, which includes bodies only partially read,
+// are left out when fields have to be redacted.
+func (l *LoggingTransport) body(data []byte, header http.Header) string {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+
+	if isJSON && len(l.RedactFields) > 0 && len(data) > 0 {
+		redacted, err := RedactJSON(data, l.RedactFields)
+		if err != nil {
+			return "[body omitted]"
+		}
+		data = redacted
+	}
+
+	maxSize := l.MaxBodySize
+	if maxSize == 0 {
+		maxSize = 1024
+	}
+	if len(data) > maxSize {
+		return string(data[:maxSize]) + "...(truncated)"
+	}
+	return string(data)
+}
+
+// RedactJSON returns the JSON document data with the values at the given dotted paths, such as
+// "password" or "card.number", replaced by [REDACTED]. Keys match case-insensitively and arrays
+// along a path apply the rest of it to each of their elements.
+func RedactJSON(data []byte, fields []string) ([]byte, error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		redactJSON(doc, strings.Split(field, "."))
+	}
+	return json.Marshal(doc)
+}
+
+// redactJSON replaces the value at path in doc
+func redactJSON(doc interface{}, path []string) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if !strings.EqualFold(key, path[0]) {
+				continue
+			}
+			if len(path) == 1 {
+				v[key] = redactedValue
+			} else {
+				redactJSON(value, path[1:])
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			redactJSON(value, path)
+		}
+	}
+}
+
+// loggingBody captures the start of a response body and writes the log entry once it is read
+// to the end or closed
+type loggingBody struct {
+	io.ReadCloser
+	log  func(body []byte)
+	buf  bytes.Buffer
+	once sync.Once
+}
+
+func (b *loggingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	room := maxLoggedCapture - b.buf.Len()
+	b.buf.Write(p[:min(n, room)])
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *loggingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+func (b *loggingBody) done() {
+	b.once.Do(func() {
+		b.log(b.buf.Bytes())
+	})
+}
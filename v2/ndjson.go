@@ -0,0 +1,180 @@
+package toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// NDJSONReader reads newline delimited JSON (JSON Lines) from a request body one value at a time.
+// Every line is decoded with the same rules as ReadJSON and may not be larger than MaxJSONSize;
+// there is no limit on the number of lines. Blank lines are skipped. Gzip and deflate encoded
+// bodies are decompressed.
+type NDJSONReader struct {
+	t       *Tools
+	err     error
+	scanner *bufio.Scanner
+	line    int
+	maxSize int
+}
+
+// NewNDJSONReader returns a reader for the newline delimited JSON body of r
+func (t *Tools) NewNDJSONReader(r *http.Request) *NDJSONReader {
+	maxBytes := t.maxJSONSize()
+
+	body, err := decompressBody(r)
+	if err != nil {
+		return &NDJSONReader{t: t, err: err}
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, min(4096, maxBytes)), maxBytes)
+
+	return &NDJSONReader{
+		t:       t,
+		scanner: scanner,
+		maxSize: maxBytes,
+	}
+}
+
+// Next decodes the next value into data. It returns io.EOF when there are no values left.
+// Errors include the number of the offending line.
+func (d *NDJSONReader) Next(data interface{}) error {
+	if d.err != nil {
+		return d.err
+	}
+
+	for d.scanner.Scan() {
+		d.line++
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		err := d.t.decodeJSON(bytes.NewReader(line), data)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", d.line, err)
+		}
+		return nil
+	}
+
+	err := d.scanner.Err()
+	switch {
+	case err == nil:
+		return io.EOF
+
+	case errors.Is(err, bufio.ErrTooLong):
+		return fmt.Errorf("line %d: line must not be larger than %d bytes", d.line+1, d.maxSize)
+
+	default:
+		return fmt.Errorf("line %d: %w", d.line+1, err)
+	}
+}
+
+// Line returns the number of the line that was read last
+func (d *NDJSONReader) Line() int {
+	return d.line
+}
+
+// ReadNDJSON returns an iterator over the values of a newline delimited JSON request body.
+// Iteration stops after the first error, which is yielded together with the zero value.
+func ReadNDJSON[T any](t *Tools, r *http.Request) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		d := t.NewNDJSONReader(r)
+		for {
+			var v T
+			err := d.Next(&v)
+			if err == io.EOF {
+				return
+			}
+			if !yield(v, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// NDJSONWriter writes values to a ResponseWriter as newline delimited JSON, flushing after every
+// record. The status and headers are sent with the first record, or by Close when nothing was
+// written, so a handler can still respond with ErrorJSON if it fails before the first record.
+type NDJSONWriter struct {
+	t           *Tools
+	w           http.ResponseWriter
+	status      int
+	headers     []http.Header
+	wroteHeader bool
+}
+
+// NewNDJSONWriter returns a writer that streams records to w
+func (t *Tools) NewNDJSONWriter(w http.ResponseWriter, status int, headers ...http.Header) *NDJSONWriter {
+	return &NDJSONWriter{
+		t:       t,
+		w:       w,
+		status:  status,
+		headers: headers,
+	}
+}
+
+// Write encodes data as a single line with the JSONEngine and DisableHTMLEscape of the Tools
+// and flushes it to the client
+func (n *NDJSONWriter) Write(data interface{}) error {
+	out, err := n.t.marshalCompactJSON(data)
+	if err != nil {
+		return err
+	}
+
+	n.writeHeader()
+	_, err = n.w.Write(append(out, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return n.flush()
+}
+
+// Close sends the status and headers if no record has been written yet
+func (n *NDJSONWriter) Close() error {
+	n.writeHeader()
+	return n.flush()
+}
+
+func (n *NDJSONWriter) writeHeader() {
+	if n.wroteHeader {
+		return
+	}
+	n.wroteHeader = true
+
+	if len(n.headers) > 0 {
+		for key, value := range n.headers[0] {
+			n.w.Header()[key] = value
+		}
+	}
+
+	n.w.Header().Set("Content-Type", "application/x-ndjson")
+	n.w.WriteHeader(n.status)
+}
+
+func (n *NDJSONWriter) flush() error {
+	err := http.NewResponseController(n.w).Flush()
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
+// WriteNDJSON streams every value of seq to w as newline delimited JSON. It stops at the first
+// value that can not be encoded or written.
+func WriteNDJSON[T any](t *Tools, w http.ResponseWriter, status int, seq iter.Seq[T], headers ...http.Header) error {
+	n := t.NewNDJSONWriter(w, status, headers...)
+	for v := range seq {
+		err := n.Write(v)
+		if err != nil {
+			return err
+		}
+	}
+	return n.Close()
+}
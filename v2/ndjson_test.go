@@ -0,0 +1,146 @@
+package toolkit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type ndjsonRecord struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+var ndjsonTests = []struct {
+	name          string
+	body          string
+	maxSize       int
+	expected      int
+	errorContains string
+}{
+	{name: "valid", body: "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n", expected: 3},
+	{name: "no trailing newline", body: "{\"id\":1}\n{\"id\":2}", expected: 2},
+	{name: "blank lines", body: "{\"id\":1}\n\n\r\n{\"id\":2}\n", expected: 2},
+	{name: "crlf", body: "{\"id\":1}\r\n{\"id\":2}\r\n", expected: 2},
+	{name: "empty body", body: "", expected: 0},
+	{name: "bad json", body: "{\"id\":1}\n{\"id\":}\n", expected: 1, errorContains: "line 2: body contains badly-formed JSON"},
+	{name: "unknown field", body: "{\"id\":1}\n{\"id\":2}\n{\"foo\":1}\n", expected: 2, errorContains: "line 3: body contains unknown key"},
+	{name: "two values on a line", body: "{\"id\":1}{\"id\":2}\n", expected: 0, errorContains: "line 1: body contains more than one json value"},
+	{name: "line too long", body: "{\"id\":1}\n{\"name\":\"abcdefghijklmnopqrstuvwxyz\"}\n", maxSize: 20, expected: 1, errorContains: "line 2: line must not be larger than 20 bytes"},
+}
+
+func TestTools_NDJSONReader(t *testing.T) {
+	for _, e := range ndjsonTests {
+		testTools := Tools{MaxJSONSize: e.maxSize}
+		req := httptest.NewRequest("POST", "/", strings.NewReader(e.body))
+		d := testTools.NewNDJSONReader(req)
+
+		count := 0
+		var err error
+		for {
+			var rec ndjsonRecord
+			err = d.Next(&rec)
+			if err != nil {
+				break
+			}
+			count++
+		}
+
+		if count != e.expected {
+			t.Errorf("%s: expected %d records but got %d", e.name, e.expected, count)
+		}
+		if e.errorContains == "" && err != io.EOF {
+			t.Errorf("%s: expected io.EOF but got %v", e.name, err)
+		}
+		if e.errorContains != "" && (err == nil || !strings.Contains(err.Error(), e.errorContains)) {
+			t.Errorf("%s: expected error containing %q but got %v", e.name, e.errorContains, err)
+		}
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	var testTools Tools
+	req := httptest.NewRequest("POST", "/", strings.NewReader("{\"id\":1}\n{\"id\":2}\n{\"id\":\"x\"}\n{\"id\":4}\n"))
+
+	var ids []int
+	var errs []error
+	for rec, err := range ReadNDJSON[ndjsonRecord](&testTools, req) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ids = append(ids, rec.ID)
+	}
+
+	if !slices.Equal(ids, []int{1, 2}) {
+		t.Errorf("wrong records read: %v", ids)
+	}
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "line 3:") {
+		t.Errorf("expected a single error on line 3 but got %v", errs)
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var testTools Tools
+	rr := httptest.NewRecorder()
+
+	headers := make(http.Header)
+	headers.Set("Foo", "Bar")
+
+	records := slices.Values([]ndjsonRecord{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}})
+	err := WriteNDJSON(&testTools, rr, http.StatusOK, records, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Error("wrong content type", rr.Header().Get("Content-Type"))
+	}
+	if rr.Header().Get("Foo") != "Bar" {
+		t.Error("custom header not set")
+	}
+	if !rr.Flushed {
+		t.Error("records were not flushed")
+	}
+
+	expected := "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\"}\n"
+	if rr.Body.String() != expected {
+		t.Errorf("wrong body: %q", rr.Body.String())
+	}
+}
+
+func TestTools_NDJSONWriter_Empty(t *testing.T) {
+	var testTools Tools
+	rr := httptest.NewRecorder()
+
+	n := testTools.NewNDJSONWriter(rr, http.StatusAccepted)
+	err := n.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("wrong status code; expected 202, got %d", rr.Code)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("expected empty body but got %q", rr.Body.String())
+	}
+}
+
+func TestTools_NDJSONWriter_Encoding(t *testing.T) {
+	testTools := Tools{DisableHTMLEscape: true, JSONIndent: "  "}
+	rr := httptest.NewRecorder()
+
+	n := testTools.NewNDJSONWriter(rr, http.StatusOK)
+	err := n.Write(map[string]string{"html": "<b>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// records stay on a single line even with JSONIndent
+	if rr.Body.String() != "{\"html\":\"<b>\"}\n" {
+		t.Errorf("wrong body: %q", rr.Body.String())
+	}
+}
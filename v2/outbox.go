@@ -0,0 +1,303 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeliveryStatus is the state of a Delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead"
+)
+
+// ErrDeliveryNotFound is returned by an Outbox for unknown delivery IDs
+var ErrDeliveryNotFound = errors.New("delivery not found")
+
+// Delivery is a JSON payload queued for a remote URL
+type Delivery struct {
+	ID          string          `json:"id"`
+	URL         string          `json:"url"`
+	Payload     json.RawMessage `json:"payload"`
+	Headers     http.Header     `json:"headers,omitempty"`
+	Status      DeliveryStatus  `json:"status"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Outbox stores the deliveries of a Dispatcher. Implementations must be safe for concurrent use.
+type Outbox interface {
+	// Put inserts or replaces a delivery
+	Put(d *Delivery) error
+	// Get returns the delivery with the given ID or ErrDeliveryNotFound
+	Get(id string) (*Delivery, error)
+	// Delete removes a delivery. Deleting an unknown delivery is not an error.
+	Delete(id string) error
+	// List returns the deliveries with the given status, oldest first
+	List(status DeliveryStatus) ([]*Delivery, error)
+}
+
+// MemoryOutbox is an Outbox that keeps deliveries in memory. Deliveries are lost when the
+// process exits.
+type MemoryOutbox struct {
+	mu         sync.Mutex
+	deliveries map[string]Delivery
+}
+
+// NewMemoryOutbox returns an empty MemoryOutbox
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{deliveries: make(map[string]Delivery)}
+}
+
+func (m *MemoryOutbox) Put(d *Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deliveries[d.ID] = *d
+	return nil
+}
+
+func (m *MemoryOutbox) Get(id string) (*Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	return &d, nil
+}
+
+func (m *MemoryOutbox) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.deliveries, id)
+	return nil
+}
+
+func (m *MemoryOutbox) List(status DeliveryStatus) ([]*Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var list []*Delivery
+	for _, d := range m.deliveries {
+		if d.Status == status {
+			list = append(list, &d)
+		}
+	}
+	sortDeliveries(list)
+	return list, nil
+}
+
+// FileOutbox is an Outbox that stores every delivery as a JSON file in a directory, so queued
+// deliveries survive a restart. Deliveries are kept in a subdirectory per status, so listing
+// the pending deliveries does not read the delivered ones. Files are replaced atomically.
+type FileOutbox struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// deliveryStatuses are the subdirectories of a FileOutbox
+var deliveryStatuses = []DeliveryStatus{DeliveryPending, DeliveryDelivered, DeliveryDead}
+
+// NewFileOutbox returns a FileOutbox storing deliveries in dir, which is created if needed
+func NewFileOutbox(dir string) (*FileOutbox, error) {
+	for _, status := range deliveryStatuses {
+		err := os.MkdirAll(filepath.Join(dir, string(status)), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &FileOutbox{dir: dir}, nil
+}
+
+func (f *FileOutbox) Put(d *Delivery) error {
+	path, err := f.path(d.Status, d.ID)
+	if err != nil {
+		return err
+	}
+	out, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".delivery-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(out)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	// remove the file of the previous status
+	for _, status := range deliveryStatuses {
+		if status == d.Status {
+			continue
+		}
+		err = f.remove(status, d.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FileOutbox) Get(id string) (*Delivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// a crash during Put can leave a delivery under two statuses, the last update wins
+	var found *Delivery
+	for _, status := range deliveryStatuses {
+		path, err := f.path(status, id)
+		if err != nil {
+			return nil, ErrDeliveryNotFound
+		}
+		d, err := readDelivery(path)
+		if errors.Is(err, ErrDeliveryNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if found == nil || d.UpdatedAt.After(found.UpdatedAt) {
+			found = d
+		}
+	}
+	if found == nil {
+		return nil, ErrDeliveryNotFound
+	}
+	return found, nil
+}
+
+func (f *FileOutbox) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, status := range deliveryStatuses {
+		err := f.remove(status, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FileOutbox) List(status DeliveryStatus) ([]*Delivery, error) {
+	dir, err := f.statusDir(status)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Delivery
+	for _, path := range paths {
+		d, err := readDelivery(path)
+		if errors.Is(err, ErrDeliveryNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	sortDeliveries(list)
+	return list, nil
+}
+
+// remove deletes the file of a delivery with the given status if it exists
+func (f *FileOutbox) remove(status DeliveryStatus, id string) error {
+	path, err := f.path(status, id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path returns the file of a delivery, rejecting IDs that would escape the directory
+func (f *FileOutbox) path(status DeliveryStatus, id string) (string, error) {
+	dir, err := f.statusDir(status)
+	if err != nil {
+		return "", err
+	}
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", errors.New("invalid delivery id")
+	}
+	return filepath.Join(dir, id+".json"), nil
+}
+
+func (f *FileOutbox) statusDir(status DeliveryStatus) (string, error) {
+	for _, known := range deliveryStatuses {
+		if status == known {
+			return filepath.Join(f.dir, string(status)), nil
+		}
+	}
+	return "", fmt.Errorf("unknown delivery status %q", status)
+}
+
+func readDelivery(path string) (*Delivery, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var d Delivery
+	err = json.Unmarshal(content, &d)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func sortDeliveries(list []*Delivery) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+}
@@ -0,0 +1,192 @@
+package toolkit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PageRequest holds the validated pagination parameters of a list request. Page based requests
+// have Page set (starting at 1) and cursor based requests have Cursor set.
+type PageRequest struct {
+	Page   int
+	Size   int
+	Offset int
+	Cursor string
+}
+
+// Page is the envelope written by WritePage as the Data of a JSONResponse
+type Page struct {
+	Items      interface{} `json:"items"`
+	Total      int         `json:"total"`
+	Page       int         `json:"page,omitempty"`
+	Size       int         `json:"size"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+}
+
+// ReadPage parses the page, size and cursor query parameters. Size defaults to DefaultPageSize
+// (20) and may not exceed MaxPageSize (100). A cursor must carry a valid signature, see
+// EncodeCursor, and can not be combined with page.
+func (t *Tools) ReadPage(r *http.Request) (PageRequest, error) {
+	query := r.URL.Query()
+
+	p := PageRequest{Page: 1, Size: 20}
+	if t.DefaultPageSize != 0 {
+		p.Size = t.DefaultPageSize
+	}
+	maxSize := 100
+	if t.MaxPageSize != 0 {
+		maxSize = t.MaxPageSize
+	}
+
+	if s := query.Get("size"); s != "" {
+		size, err := strconv.Atoi(s)
+		if err != nil || size < 1 {
+			return PageRequest{}, errors.New("size must be a positive integer")
+		}
+		if size > maxSize {
+			return PageRequest{}, fmt.Errorf("size must not be larger than %d", maxSize)
+		}
+		p.Size = size
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		if query.Get("page") != "" {
+			return PageRequest{}, errors.New("page and cursor can not be used together")
+		}
+		_, err := t.verifyCursor(cursor)
+		if err != nil {
+			return PageRequest{}, err
+		}
+		p.Page = 0
+		p.Cursor = cursor
+		return p, nil
+	}
+
+	if s := query.Get("page"); s != "" {
+		page, err := strconv.Atoi(s)
+		if err != nil || page < 1 {
+			return PageRequest{}, errors.New("page must be a positive integer")
+		}
+		// the offset of the page must fit in an int
+		if page-1 > math.MaxInt/p.Size {
+			return PageRequest{}, fmt.Errorf("page must not be larger than %d", math.MaxInt/p.Size+1)
+		}
+		p.Page = page
+	}
+	p.Offset = (p.Page - 1) * p.Size
+
+	return p, nil
+}
+
+// EncodeCursor encodes v as an opaque cursor signed with CursorSecret, so clients can not forge
+// or modify it.
+func (t *Tools) EncodeCursor(v interface{}) (string, error) {
+	if len(t.CursorSecret) == 0 {
+		return "", errors.New("cursor secret is not configured")
+	}
+
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, t.CursorSecret)
+	mac.Write(payload)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// DecodeCursor verifies the signature of a cursor created by EncodeCursor and decodes it into v
+func (t *Tools) DecodeCursor(cursor string, v interface{}) error {
+	payload, err := t.verifyCursor(cursor)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
+
+func (t *Tools) verifyCursor(cursor string) ([]byte, error) {
+	if len(t.CursorSecret) == 0 {
+		return nil, errors.New("cursor secret is not configured")
+	}
+
+	invalid := errors.New("cursor is invalid")
+
+	encodedPayload, encodedSignature, found := strings.Cut(cursor, ".")
+	if !found {
+		return nil, invalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, invalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, invalid
+	}
+
+	mac := hmac.New(sha256.New, t.CursorSecret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, invalid
+	}
+
+	return payload, nil
+}
+
+// WritePage writes page as the Data of a JSONResponse, or passes it to SuccessEnvelope when one
+// is configured, and adds an RFC 8288 Link header with the next, prev, first and last pages.
+// Page based links are derived from Page, Size and Total; cursor based links from NextCursor and
+// PrevCursor. Other query parameters are preserved.
+func (t *Tools) WritePage(w http.ResponseWriter, r *http.Request, status int, page Page, headers ...http.Header) error {
+	var links []string
+	link := func(rel, key, value string) {
+		query := r.URL.Query()
+		query.Del("page")
+		query.Del("cursor")
+		query.Set(key, value)
+		u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", u.String(), rel))
+	}
+
+	if page.Page > 0 && page.Size > 0 {
+		last := (page.Total + page.Size - 1) / page.Size
+		if page.Page < last {
+			link("next", "page", strconv.Itoa(page.Page+1))
+		}
+		if page.Page > 1 {
+			link("prev", "page", strconv.Itoa(min(page.Page-1, max(last, 1))))
+		}
+		link("first", "page", "1")
+		if last > 0 {
+			link("last", "page", strconv.Itoa(last))
+		}
+	} else {
+		if page.NextCursor != "" {
+			link("next", "cursor", page.NextCursor)
+		}
+		if page.PrevCursor != "" {
+			link("prev", "cursor", page.PrevCursor)
+		}
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	if t.SuccessEnvelope != nil {
+		return t.writeJSON(w, r, status, page, headers...)
+	}
+	return t.writeJSON(w, r, status, JSONResponse{Data: page}, headers...)
+}
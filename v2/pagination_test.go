@@ -0,0 +1,147 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var readPageTests = []struct {
+	name          string
+	query         string
+	expected      PageRequest
+	errorExpected bool
+}{
+	{name: "defaults", query: "", expected: PageRequest{Page: 1, Size: 20, Offset: 0}},
+	{name: "page and size", query: "?page=3&size=10", expected: PageRequest{Page: 3, Size: 10, Offset: 20}},
+	{name: "size too large", query: "?size=101", errorExpected: true},
+	{name: "size zero", query: "?size=0", errorExpected: true},
+	{name: "negative page", query: "?page=-1", errorExpected: true},
+	{name: "not a number", query: "?page=abc", errorExpected: true},
+	{name: "offset overflow", query: "?page=9223372036854775807&size=100", errorExpected: true},
+	{name: "tampered cursor", query: "?cursor=eyJpZCI6MTB9.AAAA", errorExpected: true},
+	{name: "malformed cursor", query: "?cursor=abc", errorExpected: true},
+}
+
+func TestTools_ReadPage(t *testing.T) {
+	testTools := Tools{CursorSecret: []byte("secret")}
+	for _, e := range readPageTests {
+		p, err := testTools.ReadPage(httptest.NewRequest("GET", "/items"+e.query, nil))
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected but none received", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+		}
+		if p != e.expected {
+			t.Errorf("%s: expected %+v but got %+v", e.name, e.expected, p)
+		}
+	}
+}
+
+func TestTools_Cursor(t *testing.T) {
+	testTools := Tools{CursorSecret: []byte("secret"), MaxPageSize: 50}
+
+	type position struct {
+		ID int `json:"id"`
+	}
+	cursor, err := testTools.EncodeCursor(position{ID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := testTools.ReadPage(httptest.NewRequest("GET", "/items?size=50&cursor="+cursor, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Cursor != cursor || p.Page != 0 || p.Size != 50 {
+		t.Errorf("wrong page request %+v", p)
+	}
+
+	var decoded position
+	err = testTools.DecodeCursor(p.Cursor, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID != 10 {
+		t.Errorf("wrong cursor position %d", decoded.ID)
+	}
+
+	other := Tools{CursorSecret: []byte("other")}
+	if err = other.DecodeCursor(cursor, &decoded); err == nil {
+		t.Error("cursor signed with another secret accepted")
+	}
+
+	if _, err = testTools.ReadPage(httptest.NewRequest("GET", "/items?page=2&cursor="+cursor, nil)); err == nil {
+		t.Error("page and cursor accepted together")
+	}
+
+	var noSecret Tools
+	if _, err = noSecret.EncodeCursor(position{ID: 1}); err == nil {
+		t.Error("cursor encoded without a secret")
+	}
+}
+
+func TestTools_WritePage(t *testing.T) {
+	var testTools Tools
+
+	tests := []struct {
+		name  string
+		url   string
+		page  Page
+		links []string
+	}{
+		{
+			name:  "middle page",
+			url:   "/items?page=2&size=10&sort=name",
+			page:  Page{Items: []int{11, 12}, Total: 35, Page: 2, Size: 10},
+			links: []string{`</items?page=3&size=10&sort=name>; rel="next"`, `</items?page=1&size=10&sort=name>; rel="prev"`, `</items?page=1&size=10&sort=name>; rel="first"`, `</items?page=4&size=10&sort=name>; rel="last"`},
+		},
+		{
+			name:  "last page",
+			url:   "/items?page=4&size=10",
+			page:  Page{Items: []int{31}, Total: 31, Page: 4, Size: 10},
+			links: []string{`</items?page=3&size=10>; rel="prev"`, `</items?page=1&size=10>; rel="first"`, `</items?page=4&size=10>; rel="last"`},
+		},
+		{
+			name:  "cursor",
+			url:   "/items?cursor=abc&size=10",
+			page:  Page{Items: []int{1}, Total: 100, Size: 10, NextCursor: "def", PrevCursor: "xyz"},
+			links: []string{`</items?cursor=def&size=10>; rel="next"`, `</items?cursor=xyz&size=10>; rel="prev"`},
+		},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		err := testTools.WritePage(rr, httptest.NewRequest("GET", e.url, nil), http.StatusOK, e.page)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+			continue
+		}
+
+		if link := rr.Header().Get("Link"); link != strings.Join(e.links, ", ") {
+			t.Errorf("%s: wrong link header %s", e.name, link)
+		}
+
+		var payload struct {
+			Error bool `json:"error"`
+			Data  struct {
+				Items []int  `json:"items"`
+				Total int    `json:"total"`
+				Next  string `json:"next_cursor"`
+			} `json:"data"`
+		}
+		err = json.Unmarshal(rr.Body.Bytes(), &payload)
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+		if payload.Error || payload.Data.Total != e.page.Total || payload.Data.Next != e.page.NextCursor {
+			t.Errorf("%s: wrong envelope %s", e.name, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,623 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is a single operation of an RFC 6902 JSON Patch document. Value is nil when the
+// member is absent and "null" when it is explicitly set to null.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ReadMergePatch reads an RFC 7396 JSON Merge Patch from the request body and applies it to
+// target, which must be a pointer. It returns the JSON Pointers of the members that were set or
+// removed. The body is subject to the same size limit as ReadJSON.
+func (t *Tools) ReadMergePatch(w http.ResponseWriter, r *http.Request, target interface{}) ([]string, error) {
+	patch, err := t.readPatchBody(w, r, "application/merge-patch+json")
+	if err != nil {
+		return nil, err
+	}
+
+	return t.patchValue(target, func(doc []byte) ([]byte, []string, error) {
+		return MergePatch(doc, patch)
+	})
+}
+
+// ReadJSONPatch reads an RFC 6902 JSON Patch document from the request body and applies it to
+// target, which must be a pointer. It returns the JSON Pointers of the locations that were
+// changed. The body is subject to the same size limit as ReadJSON.
+func (t *Tools) ReadJSONPatch(w http.ResponseWriter, r *http.Request, target interface{}) ([]string, error) {
+	body, err := t.readPatchBody(w, r, "application/json-patch+json")
+	if err != nil {
+		return nil, err
+	}
+
+	// members that are not defined for an operation must be ignored
+	lenient := *t
+	lenient.AllowUnknownFields = true
+
+	var ops []PatchOperation
+	err = lenient.decodeJSON(bytes.NewReader(body), &ops)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.patchValue(target, func(doc []byte) ([]byte, []string, error) {
+		return ApplyJSONPatch(doc, addOmittedFields(doc, reflect.TypeOf(target), ops))
+	})
+}
+
+// addOmittedFields turns the replace operations on struct fields left out of doc by omitempty
+// into add operations, as the fields exist even though their zero value is not marshalled
+func addOmittedFields(doc []byte, typ reflect.Type, ops []PatchOperation) []PatchOperation {
+	parsed, err := unmarshalPatchJSON(doc)
+	if err != nil {
+		return ops
+	}
+
+	var result []PatchOperation
+	for i, op := range ops {
+		if op.Op != "replace" {
+			continue
+		}
+		path, err := parsePointer(op.Path)
+		if err != nil || len(path) == 0 {
+			continue
+		}
+		if _, err = pointerGet(parsed, path); err == nil {
+			continue
+		}
+		parent, err := pointerGet(parsed, path[:len(path)-1])
+		if _, ok := parent.(map[string]interface{}); err != nil || !ok || !isStructField(typ, path) {
+			continue
+		}
+
+		if result == nil {
+			result = slices.Clone(ops)
+		}
+		result[i].Op = "add"
+	}
+
+	if result == nil {
+		return ops
+	}
+	return result
+}
+
+// isStructField reports whether path leads to a field of a struct in values of type typ
+func isStructField(typ reflect.Type, path []string) bool {
+	for i, token := range path {
+		typ = derefType(typ)
+		switch typ.Kind() {
+		case reflect.Struct:
+			fields := make(map[string]reflect.Type)
+			collectJSONFields(typ, fields)
+			fieldType, ok := fields[token]
+			if !ok {
+				return false
+			}
+			if i == len(path)-1 {
+				return true
+			}
+			typ = fieldType
+		case reflect.Slice, reflect.Array, reflect.Map:
+			typ = typ.Elem()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// readPatchBody reads the whole body, accepting the given patch media type or plain JSON
+func (t *Tools) readPatchBody(w http.ResponseWriter, r *http.Request, mediaType string) ([]byte, error) {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mt, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mt != mediaType && mt != "application/json") {
+			return nil, &StatusError{
+				Status: http.StatusUnsupportedMediaType,
+				Err:    fmt.Errorf("content type must be %s", mediaType),
+			}
+		}
+	}
+
+	err := t.limitBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, jsonDecodeError(err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, errors.New("body must not be empty")
+	}
+	if !json.Valid(body) {
+		return nil, errors.New("body contains badly-formed JSON")
+	}
+
+	return body, nil
+}
+
+// patchValue marshals target, patches the JSON and decodes the result into a copy of target
+// whose JSON fields are reset, so members removed by the patch get their zero value while fields
+// that are not part of the JSON, unexported or tagged "-", keep theirs.
+func (t *Tools) patchValue(target interface{}, apply func(doc []byte) ([]byte, []string, error)) ([]string, error) {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil, fmt.Errorf("patch target must be a non-nil pointer, got %T", target)
+	}
+
+	doc, err := json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, paths, err := apply(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	fresh := reflect.New(rv.Elem().Type())
+	fresh.Elem().Set(rv.Elem())
+	resetJSONValue(fresh.Elem())
+	err = t.decodeJSON(bytes.NewReader(patched), fresh.Interface())
+	if err != nil {
+		return nil, err
+	}
+	rv.Elem().Set(fresh.Elem())
+
+	return paths, nil
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// resetJSONValue sets the parts of v that are decoded from JSON to their zero value. Structs
+// keep the fields that JSON ignores, and pointed to structs are copied before being reset so
+// the original value is not modified.
+func resetJSONValue(v reflect.Value) {
+	if !v.CanSet() {
+		return
+	}
+	if reflect.PointerTo(v.Type()).Implements(jsonUnmarshalerType) || reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		v.SetZero()
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		typ := v.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.Tag.Get("json") == "-" || (!field.IsExported() && !field.Anonymous) {
+				continue
+			}
+			resetJSONValue(v.Field(i))
+		}
+
+	case reflect.Pointer:
+		if v.IsNil() || v.Type().Elem().Kind() != reflect.Struct {
+			v.SetZero()
+			return
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(v.Elem())
+		resetJSONValue(copied.Elem())
+		v.Set(copied)
+
+	default:
+		v.SetZero()
+	}
+}
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to doc and returns the patched document along
+// with the JSON Pointers of the members that were set or removed.
+func MergePatch(doc, patch []byte) ([]byte, []string, error) {
+	target, err := unmarshalPatchJSON(doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("document contains badly-formed JSON: %w", err)
+	}
+	p, err := unmarshalPatchJSON(patch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("patch contains badly-formed JSON: %w", err)
+	}
+
+	var paths []string
+	result := mergeValue(target, p, "", &paths)
+
+	out, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, paths, nil
+}
+
+func mergeValue(target, patch interface{}, path string, paths *[]string) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		*paths = append(*paths, path)
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	keys := make([]string, 0, len(patchObject))
+	for key := range patchObject {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := patchObject[key]
+		p := path + "/" + escapePointerToken(key)
+		if value == nil {
+			if _, exists := targetObject[key]; exists {
+				delete(targetObject, key)
+				*paths = append(*paths, p)
+			}
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value, p, paths)
+	}
+
+	return targetObject
+}
+
+// ApplyJSONPatch applies the operations of an RFC 6902 JSON Patch document to doc. The operations
+// are applied atomically: when one fails doc is left untouched and an error is returned. The
+// JSON Pointers of the changed locations are returned with the patched document.
+func ApplyJSONPatch(doc []byte, ops []PatchOperation) ([]byte, []string, error) {
+	target, err := unmarshalPatchJSON(doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("document contains badly-formed JSON: %w", err)
+	}
+
+	var paths []string
+	touch := func(p string) {
+		if !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+
+	for i, op := range ops {
+		target, err = applyPatchOperation(target, op)
+		if err != nil {
+			return nil, nil, fmt.Errorf("patch operation %d (%s %q): %w", i, op.Op, op.Path, err)
+		}
+
+		switch op.Op {
+		case "test":
+		case "move":
+			touch(op.From)
+			touch(op.Path)
+		default:
+			touch(op.Path)
+		}
+	}
+
+	out, err := json.Marshal(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, paths, nil
+}
+
+func applyPatchOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, errors.New("missing value")
+		}
+		value, err = unmarshalPatchJSON(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("value contains badly-formed JSON: %w", err)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return pointerAdd(doc, path, value)
+
+	case "remove":
+		doc, _, err = pointerRemove(doc, path)
+		return doc, err
+
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		doc, _, err = pointerRemove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var v interface{}
+		if op.Op == "move" {
+			if len(from) < len(path) && slices.Equal(from, path[:len(from)]) {
+				return nil, errors.New("a value can not be moved into one of its children")
+			}
+			doc, v, err = pointerRemove(doc, from)
+		} else {
+			v, err = pointerGet(doc, from)
+			v = deepCopyJSON(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return pointerAdd(doc, path, v)
+
+	case "test":
+		current, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equalJSON(current, value) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, errors.New("path does not exist")
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, errors.New("path does not exist")
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd adds value at path and returns the updated document
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return pointerUpdate(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			i := len(node)
+			if token != "-" {
+				var err error
+				i, err = arrayIndex(token, len(node))
+				if err != nil {
+					return nil, err
+				}
+			}
+			return slices.Insert(node, i, value), nil
+		default:
+			return nil, errors.New("parent of path is not an object or array")
+		}
+	})
+}
+
+// pointerRemove removes the value at path and returns the updated document and the removed value
+func pointerRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("the document root can not be removed")
+	}
+
+	var removed interface{}
+	doc, err := pointerUpdate(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, errors.New("path does not exist")
+			}
+			removed = v
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return slices.Delete(node, i, i+1), nil
+		default:
+			return nil, errors.New("path does not exist")
+		}
+	})
+	return doc, removed, err
+}
+
+// pointerUpdate walks to the parent of the last token of path, lets fn modify it and stores the
+// modified parent back into the document.
+func pointerUpdate(doc interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, errors.New("path does not exist")
+		}
+		updated, err := pointerUpdate(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = updated
+		return node, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := pointerUpdate(node[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = updated
+		return node, nil
+	default:
+		return nil, errors.New("path does not exist")
+	}
+}
+
+// arrayIndex parses an array index token that must not be larger than last
+func arrayIndex(token string, last int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > last {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+// unmarshalPatchJSON decodes JSON keeping numbers as json.Number, so they survive a round trip
+// without losing precision.
+func unmarshalPatchJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("more than one json value")
+	}
+	return v, nil
+}
+
+func deepCopyJSON(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			c[key] = deepCopyJSON(value)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(node))
+		for i, value := range node {
+			c[i] = deepCopyJSON(value)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// equalJSON compares two decoded JSON values, treating numbers as equal when their values are
+func equalJSON(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !equalJSON(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equalJSON(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		rx, okx := parseNumber(x)
+		ry, oky := parseNumber(y)
+		if !okx || !oky {
+			// numbers too large to compare exactly are only equal as written
+			return x == y
+		}
+		return rx.Cmp(ry) == 0
+	default:
+		return a == b
+	}
+}
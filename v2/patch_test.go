@@ -0,0 +1,288 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var mergePatchTests = []struct {
+	name     string
+	doc      string
+	patch    string
+	expected string
+	paths    []string
+}{
+	{name: "replace member", doc: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`, paths: []string{"/a"}},
+	{name: "add member", doc: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b","b":"c"}`, paths: []string{"/b"}},
+	{name: "remove member", doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, expected: `{"b":"c"}`, paths: []string{"/a"}},
+	{name: "remove missing member", doc: `{"a":"b"}`, patch: `{"c":null}`, expected: `{"a":"b"}`, paths: nil},
+	{name: "replace array", doc: `{"a":["b"]}`, patch: `{"a":["c","d"]}`, expected: `{"a":["c","d"]}`, paths: []string{"/a"}},
+	{name: "nested", doc: `{"a":{"b":"c","d":1}}`, patch: `{"a":{"b":"e","d":null}}`, expected: `{"a":{"b":"e"}}`, paths: []string{"/a/b", "/a/d"}},
+	{name: "non object patch", doc: `{"a":"b"}`, patch: `["c"]`, expected: `["c"]`, paths: []string{""}},
+	{name: "object into scalar", doc: `{"a":"b"}`, patch: `{"a":{"c":1}}`, expected: `{"a":{"c":1}}`, paths: []string{"/a/c"}},
+	{name: "escaped key", doc: `{}`, patch: `{"a/b~c":1}`, expected: `{"a/b~c":1}`, paths: []string{"/a~1b~0c"}},
+	{name: "large number", doc: `{"n":12345678901234567890}`, patch: `{"m":1}`, expected: `{"m":1,"n":12345678901234567890}`, paths: []string{"/m"}},
+}
+
+func TestMergePatch(t *testing.T) {
+	for _, e := range mergePatchTests {
+		out, paths, err := MergePatch([]byte(e.doc), []byte(e.patch))
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+			continue
+		}
+		if string(out) != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, out)
+		}
+		if !slices.Equal(paths, e.paths) {
+			t.Errorf("%s: expected paths %v but got %v", e.name, e.paths, paths)
+		}
+	}
+}
+
+var jsonPatchTests = []struct {
+	name          string
+	doc           string
+	patch         string
+	expected      string
+	paths         []string
+	errorExpected bool
+}{
+	{name: "add member", doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz","value":"qux"}]`, expected: `{"baz":"qux","foo":"bar"}`, paths: []string{"/baz"}},
+	{name: "add array element", doc: `{"foo":["bar","baz"]}`, patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`, expected: `{"foo":["bar","qux","baz"]}`, paths: []string{"/foo/1"}},
+	{name: "append array element", doc: `{"foo":[1]}`, patch: `[{"op":"add","path":"/foo/-","value":2}]`, expected: `{"foo":[1,2]}`, paths: []string{"/foo/-"}},
+	{name: "add null", doc: `{}`, patch: `[{"op":"add","path":"/foo","value":null}]`, expected: `{"foo":null}`, paths: []string{"/foo"}},
+	{name: "remove member", doc: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"remove","path":"/baz"}]`, expected: `{"foo":"bar"}`, paths: []string{"/baz"}},
+	{name: "remove array element", doc: `{"foo":["bar","qux","baz"]}`, patch: `[{"op":"remove","path":"/foo/1"}]`, expected: `{"foo":["bar","baz"]}`, paths: []string{"/foo/1"}},
+	{name: "replace", doc: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"replace","path":"/baz","value":"boo"}]`, expected: `{"baz":"boo","foo":"bar"}`, paths: []string{"/baz"}},
+	{name: "replace root", doc: `{"foo":"bar"}`, patch: `[{"op":"replace","path":"","value":[1]}]`, expected: `[1]`, paths: []string{""}},
+	{name: "move", doc: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, paths: []string{"/foo/waldo", "/qux/thud"}},
+	{name: "move array element", doc: `{"foo":["all","grass","cows","eat"]}`, patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, expected: `{"foo":["all","cows","eat","grass"]}`, paths: []string{"/foo/1", "/foo/3"}},
+	{name: "copy", doc: `{"foo":{"a":1}}`, patch: `[{"op":"copy","from":"/foo","path":"/bar"},{"op":"add","path":"/bar/b","value":2}]`, expected: `{"bar":{"a":1,"b":2},"foo":{"a":1}}`, paths: []string{"/bar", "/bar/b"}},
+	{name: "test success", doc: `{"baz":"qux","foo":["a",2,"c"]}`, patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`, expected: `{"baz":"qux","foo":["a",2,"c"]}`, paths: nil},
+	{name: "test failure", doc: `{"baz":"qux"}`, patch: `[{"op":"test","path":"/baz","value":"bar"}]`, errorExpected: true},
+	{name: "test huge number", doc: `{"x":1e999999}`, patch: `[{"op":"test","path":"/x","value":1e999999}]`, expected: `{"x":1e999999}`, paths: nil},
+	{name: "test huge number failure", doc: `{"x":1e999999}`, patch: `[{"op":"test","path":"/x","value":10e999998}]`, errorExpected: true},
+	{name: "escaped path", doc: `{"a/b":1,"m~n":2}`, patch: `[{"op":"remove","path":"/a~1b"},{"op":"replace","path":"/m~0n","value":3}]`, expected: `{"m~n":3}`, paths: []string{"/a~1b", "/m~0n"}},
+	{name: "missing parent", doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz/bat","value":"qux"}]`, errorExpected: true},
+	{name: "remove missing", doc: `{"foo":"bar"}`, patch: `[{"op":"remove","path":"/baz"}]`, errorExpected: true},
+	{name: "index out of range", doc: `{"foo":[1]}`, patch: `[{"op":"add","path":"/foo/2","value":2}]`, errorExpected: true},
+	{name: "leading zero index", doc: `{"foo":[1,2]}`, patch: `[{"op":"remove","path":"/foo/01"}]`, errorExpected: true},
+	{name: "move into child", doc: `{"foo":{"bar":1}}`, patch: `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, errorExpected: true},
+	{name: "missing value", doc: `{}`, patch: `[{"op":"add","path":"/foo"}]`, errorExpected: true},
+	{name: "unknown operation", doc: `{}`, patch: `[{"op":"merge","path":"/foo","value":1}]`, errorExpected: true},
+	{name: "invalid pointer", doc: `{}`, patch: `[{"op":"add","path":"foo","value":1}]`, errorExpected: true},
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	for _, e := range jsonPatchTests {
+		var ops []PatchOperation
+		err := json.Unmarshal([]byte(e.patch), &ops)
+		if err != nil {
+			t.Fatalf("%s: %s", e.name, err)
+		}
+
+		out, paths, err := ApplyJSONPatch([]byte(e.doc), ops)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected but none received", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+			continue
+		}
+		if string(out) != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, out)
+		}
+		if !slices.Equal(paths, e.paths) {
+			t.Errorf("%s: expected paths %v but got %v", e.name, e.paths, paths)
+		}
+	}
+}
+
+type patchUser struct {
+	Name  string  `json:"name"`
+	Email string  `json:"email"`
+	Age   int     `json:"age"`
+	Nick  *string `json:"nick"`
+}
+
+func TestTools_ReadMergePatch(t *testing.T) {
+	var testTools Tools
+	nick := "jd"
+	user := patchUser{Name: "John", Email: "john@example.com", Age: 40, Nick: &nick}
+
+	req := httptest.NewRequest("PATCH", "/", strings.NewReader(`{"email":"jd@example.com","age":0,"nick":null}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+
+	paths, err := testTools.ReadMergePatch(httptest.NewRecorder(), req, &user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Name != "John" || user.Email != "jd@example.com" || user.Age != 0 || user.Nick != nil {
+		t.Errorf("wrong patched value: %+v", user)
+	}
+	if !slices.Equal(paths, []string{"/age", "/email", "/nick"}) {
+		t.Errorf("wrong paths: %v", paths)
+	}
+}
+
+func TestTools_ReadMergePatch_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		maxSize     int
+	}{
+		{name: "unknown field", body: `{"foo":"bar"}`},
+		{name: "wrong type", body: `{"age":"old"}`},
+		{name: "bad json", body: `{"age":`},
+		{name: "empty body", body: ``},
+		{name: "too large", body: `{"name":"abcdefghij"}`, maxSize: 10},
+		{name: "wrong content type", body: `{"name":"a"}`, contentType: "text/plain"},
+	}
+
+	for _, e := range tests {
+		testTools := Tools{MaxJSONSize: e.maxSize}
+		user := patchUser{Name: "John"}
+
+		req := httptest.NewRequest("PATCH", "/", strings.NewReader(e.body))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		_, err := testTools.ReadMergePatch(httptest.NewRecorder(), req, &user)
+		if err == nil {
+			t.Errorf("%s: error expected but none received", e.name)
+		}
+		if user.Name != "John" {
+			t.Errorf("%s: target modified on error: %+v", e.name, user)
+		}
+	}
+}
+
+func TestTools_ReadJSONPatch(t *testing.T) {
+	var testTools Tools
+	user := patchUser{Name: "John", Email: "john@example.com", Age: 40}
+
+	body := `[
+		{"op":"test","path":"/age","value":40},
+		{"op":"replace","path":"/age","value":41,"comment":"ignored"},
+		{"op":"copy","from":"/name","path":"/nick"}
+	]`
+	req := httptest.NewRequest("PATCH", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+
+	paths, err := testTools.ReadJSONPatch(httptest.NewRecorder(), req, &user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Age != 41 || user.Nick == nil || *user.Nick != "John" {
+		t.Errorf("wrong patched value: %+v", user)
+	}
+	if !slices.Equal(paths, []string{"/age", "/nick"}) {
+		t.Errorf("wrong paths: %v", paths)
+	}
+
+	req = httptest.NewRequest("PATCH", "/", strings.NewReader(`[{"op":"test","path":"/age","value":1}]`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	_, err = testTools.ReadJSONPatch(httptest.NewRecorder(), req, &user)
+	if err == nil {
+		t.Error("expected error for wrong content type")
+	}
+	if statusError, ok := err.(*StatusError); !ok || statusError.Status != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 status error but got %v", err)
+	}
+}
+
+func TestTools_ReadJSONPatch_LargeNumbers(t *testing.T) {
+	var testTools Tools
+	user := patchUser{Name: "John", Age: 40}
+
+	body := "[" + strings.Repeat(`{"op":"test","path":"/age","value":1e999999},`, 1000) + `{"op":"test","path":"/age","value":40}]`
+	req := httptest.NewRequest("PATCH", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+
+	start := time.Now()
+	_, err := testTools.ReadJSONPatch(httptest.NewRecorder(), req, &user)
+	if err == nil {
+		t.Error("expected the test operation to fail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("comparing large exponents took %s", elapsed)
+	}
+}
+
+type patchAccount struct {
+	Name     string         `json:"name"`
+	Plan     string         `json:"plan,omitempty"`
+	Limits   *patchLimits   `json:"limits,omitempty"`
+	Tags     map[string]int `json:"tags,omitempty"`
+	Password string         `json:"-"`
+	version  int
+}
+
+type patchLimits struct {
+	Seats  int `json:"seats,omitempty"`
+	secret string
+}
+
+func TestTools_ReadJSONPatch_Struct(t *testing.T) {
+	var testTools Tools
+	limits := &patchLimits{secret: "s"}
+	account := patchAccount{Name: "acme", Limits: limits, Tags: map[string]int{"a": 1}, Password: "hash", version: 3}
+
+	body := `[
+		{"op":"replace","path":"/plan","value":"pro"},
+		{"op":"replace","path":"/limits/seats","value":5},
+		{"op":"remove","path":"/tags"}
+	]`
+	req := httptest.NewRequest("PATCH", "/", strings.NewReader(body))
+	paths, err := testTools.ReadJSONPatch(httptest.NewRecorder(), req, &account)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account.Plan != "pro" || account.Limits.Seats != 5 || account.Tags != nil {
+		t.Errorf("wrong patched value: %+v", account)
+	}
+	if account.Password != "hash" || account.version != 3 || account.Limits.secret != "s" {
+		t.Errorf("fields outside of the JSON were reset: %+v %+v", account, account.Limits)
+	}
+	if limits.Seats != 0 {
+		t.Error("the original nested value was modified")
+	}
+	if !slices.Equal(paths, []string{"/plan", "/limits/seats", "/tags"}) {
+		t.Errorf("wrong paths: %v", paths)
+	}
+
+	// replace still fails for members that are not fields
+	req = httptest.NewRequest("PATCH", "/", strings.NewReader(`[{"op":"replace","path":"/unknown","value":1}]`))
+	_, err = testTools.ReadJSONPatch(httptest.NewRecorder(), req, &account)
+	if err == nil || !strings.Contains(err.Error(), "path does not exist") {
+		t.Errorf("expected path error but got %v", err)
+	}
+}
+
+func TestTools_ReadMergePatch_Hidden(t *testing.T) {
+	var testTools Tools
+	account := patchAccount{Name: "acme", Plan: "pro", Password: "hash", version: 3}
+
+	req := httptest.NewRequest("PATCH", "/", strings.NewReader(`{"name":"acme inc","plan":null}`))
+	_, err := testTools.ReadMergePatch(httptest.NewRecorder(), req, &account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Name != "acme inc" || account.Plan != "" || account.Password != "hash" || account.version != 3 {
+		t.Errorf("wrong patched value: %+v", account)
+	}
+}
@@ -0,0 +1,84 @@
+package toolkit
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Alphabets for RandomStringAlphabet
+const (
+	AlphabetAlphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// AlphabetURLSafe is the alphabet of base64url, safe in URLs and file names
+	AlphabetURLSafe = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	AlphabetHex     = "0123456789abcdef"
+	// AlphabetCrockford is Crockford's base32, which leaves out I, L, O and U
+	AlphabetCrockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// AlphabetHumanFriendly leaves out characters that are easily confused when read or typed,
+	// such as 0 and O or 1, l and I
+	AlphabetHumanFriendly = "23456789abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+)
+
+// randomReader is the source of randomness, replaced in tests
+var randomReader io.Reader = rand.Reader
+
+// RandomStringAlphabet returns a random string of n characters from alphabet, which must hold
+// between 2 and 256 distinct characters. Every character is equally likely: random bytes are
+// masked to the smallest power of two covering the alphabet and values outside of it are
+// discarded instead of being reduced modulo its length.
+func (t *Tools) RandomStringAlphabet(n int, alphabet string) (string, error) {
+	return randomString(n, alphabet)
+}
+
+func randomString(n int, alphabet string) (string, error) {
+	symbols := []rune(alphabet)
+	if len(symbols) < 2 || len(symbols) > 256 {
+		return "", errors.New("alphabet must contain between 2 and 256 characters")
+	}
+	seen := make(map[rune]bool, len(symbols))
+	for _, r := range symbols {
+		if seen[r] {
+			return "", fmt.Errorf("alphabet contains duplicate character %q", r)
+		}
+		seen[r] = true
+	}
+	if n <= 0 {
+		return "", nil
+	}
+
+	mask := byte(1<<bits.Len(uint(len(symbols)-1)) - 1)
+	// read enough bytes for most strings at once: on average (mask+1)/len bytes per character,
+	// plus a margin for unlucky draws
+	batch := n*(int(mask)+1)/len(symbols) + n/4 + 8
+	buf := make([]byte, batch)
+
+	s := make([]rune, 0, n)
+	for len(s) < n {
+		err := randomBytes(buf)
+		if err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			index := int(b & mask)
+			if index >= len(symbols) {
+				continue
+			}
+			s = append(s, symbols[index])
+			if len(s) == n {
+				break
+			}
+		}
+	}
+	return string(s), nil
+}
+
+// randomBytes fills buf from randomReader
+func randomBytes(buf []byte) error {
+	_, err := io.ReadFull(randomReader, buf)
+	if err != nil {
+		return fmt.Errorf("cannot read random bytes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTools_RandomStringAlphabet(t *testing.T) {
+	var tests = []struct {
+		name      string
+		alphabet  string
+		n         int
+		errorText string
+	}{
+		{name: "alphanumeric", alphabet: AlphabetAlphanumeric, n: 100},
+		{name: "url safe", alphabet: AlphabetURLSafe, n: 100},
+		{name: "hex", alphabet: AlphabetHex, n: 64},
+		{name: "crockford", alphabet: AlphabetCrockford, n: 26},
+		{name: "human friendly", alphabet: AlphabetHumanFriendly, n: 12},
+		{name: "unicode", alphabet: "αβγδ", n: 10},
+		{name: "empty", alphabet: AlphabetHex, n: 0},
+		{name: "short alphabet", alphabet: "a", n: 10, errorText: "alphabet must contain between 2 and 256 characters"},
+		{name: "duplicates", alphabet: "abca", n: 10, errorText: `alphabet contains duplicate character 'a'`},
+	}
+
+	var testTools Tools
+	for _, e := range tests {
+		s, err := testTools.RandomStringAlphabet(e.n, e.alphabet)
+		if e.errorText != "" {
+			if err == nil || err.Error() != e.errorText {
+				t.Errorf("%s: expected error %q but got %v", e.name, e.errorText, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", e.name, err)
+			continue
+		}
+		if len([]rune(s)) != e.n {
+			t.Errorf("%s: expected %d characters but got %q", e.name, e.n, s)
+		}
+		for _, r := range s {
+			if !strings.ContainsRune(e.alphabet, r) {
+				t.Errorf("%s: %q is not in the alphabet", e.name, r)
+			}
+		}
+	}
+}
+
+func TestTools_RandomStringAlphabet_Distribution(t *testing.T) {
+	var testTools Tools
+
+	s, err := testTools.RandomStringAlphabet(62000, AlphabetAlphanumeric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	if len(counts) != 62 {
+		t.Errorf("expected 62 distinct characters but got %d", len(counts))
+	}
+	for r, count := range counts {
+		if count < 800 || count > 1200 {
+			t.Errorf("character %q drawn %d times, expected about 1000", r, count)
+		}
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("no entropy")
+}
+
+func TestTools_RandomStringAlphabet_Source(t *testing.T) {
+	original := randomReader
+	defer func() { randomReader = original }()
+	var testTools Tools
+
+	// 62 and 63 are outside of the alphanumeric alphabet and must be discarded
+	randomReader = bytes.NewReader(bytes.Repeat([]byte{62, 63, 0, 1 + 64}, 100))
+	s, err := testTools.RandomStringAlphabet(6, AlphabetAlphanumeric)
+	if err != nil || s != "ababab" {
+		t.Errorf("expected ababab but got %q %v", s, err)
+	}
+
+	randomReader = failingReader{}
+	_, err = testTools.RandomStringAlphabet(6, AlphabetAlphanumeric)
+	if err == nil || err.Error() != "cannot read random bytes: no entropy" {
+		t.Errorf("expected a randomness error but got %v", err)
+	}
+
+	// generated keys and IDs return the error instead of panicking
+	_, _, err = testTools.PushJSONToRemoteContext(context.Background(), "http://someurl", map[string]int{"n": 1}, PushOptions{Retry: RetryPolicy{MaxAttempts: 2}})
+	if err == nil || err.Error() != "cannot read random bytes: no entropy" {
+		t.Errorf("expected a randomness error from the push but got %v", err)
+	}
+	_, err = testTools.NewDispatcher(NewMemoryOutbox()).Enqueue("http://someurl", map[string]int{"n": 1})
+	if err == nil || err.Error() != "cannot read random bytes: no entropy" {
+		t.Errorf("expected a randomness error from the dispatcher but got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RandomString did not panic")
+		}
+	}()
+	testTools.RandomString(6)
+}
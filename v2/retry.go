@@ -0,0 +1,231 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how PushJSONToRemoteContext retries failed attempts. Network errors,
+// 429 Too Many Requests and 5xx responses are retried with exponential backoff and jitter; a
+// Retry-After header on the response takes precedence over the computed backoff. An AuthError
+// is not retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts. Zero or one disables retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, 100ms by default. It is multiplied by
+	// Multiplier (2 by default) for every following retry, up to MaxBackoff (10s by default).
+	// A Retry-After longer than MaxBackoff ends the retries.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Retryable overrides the default decision of which results are retried. err is non-nil
+	// when no response was received.
+	Retryable func(res *http.Response, err error) bool
+}
+
+// PushOptions configures PushJSONToRemoteContext
+type PushOptions struct {
+	// Client sends the requests, http.DefaultClient by default
+	Client *http.Client
+	Retry  RetryPolicy
+	// AttemptTimeout bounds every single attempt, including reading the response body
+	AttemptTimeout time.Duration
+	// IdempotencyKey is sent as the Idempotency-Key header of every attempt. When retries are
+	// enabled and no key is given, a random key is generated for the push.
+	IdempotencyKey string
+	Headers        http.Header
+	// Auth adds credentials to every attempt
+	Auth Authenticator
+	// Signer signs every attempt with a fresh timestamp
+	Signer *Signer
+}
+
+// PushJSONToRemoteContext posts data as JSON to uri like PushJSONToRemote, retrying transient
+// failures according to the retry policy in opts. The last response is returned even when its
+// status is not successful; its body is left open and must be closed by the caller. An error
+// is returned when no response could be obtained or ctx is done.
+func (t *Tools) PushJSONToRemoteContext(ctx context.Context, uri string, data interface{}, opts ...PushOptions) (*http.Response, int, error) {
+	var opt PushOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	client := opt.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	policy := opt.Retry
+	attempts := max(policy.MaxAttempts, 1)
+
+	key := opt.IdempotencyKey
+	if key == "" && attempts > 1 {
+		key, err = randomString(32, AlphabetAlphanumeric)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, err := t.pushAttempt(ctx, client, uri, jsonData, key, opt)
+
+		if err != nil && ctx.Err() != nil {
+			return nil, 0, fmt.Errorf("push to %s cancelled after %d attempts: %w", uri, attempt, ctx.Err())
+		}
+		if attempt >= attempts || ctx.Err() != nil || !policy.retryable(res, err) {
+			if err != nil && attempt > 1 {
+				return nil, 0, fmt.Errorf("push to %s failed after %d attempts: %w", uri, attempt, err)
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			return res, res.StatusCode, nil
+		}
+
+		delay := policy.backoff(attempt)
+		if res != nil {
+			if after, ok := retryAfter(res); ok {
+				if after > policy.maxBackoff() {
+					return res, res.StatusCode, nil
+				}
+				delay = after
+			}
+			// drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, 0, fmt.Errorf("push to %s cancelled after %d attempts: %w", uri, attempt, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// pushAttempt sends a single request, bounded by the attempt timeout
+func (t *Tools) pushAttempt(ctx context.Context, client *http.Client, uri string, body []byte, key string, opt PushOptions) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if opt.AttemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opt.AttemptTimeout)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	for header, values := range opt.Headers {
+		req.Header[header] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	if opt.Auth != nil {
+		err = opt.Auth.Authenticate(req)
+		if err != nil {
+			cancel()
+			return nil, &AuthError{Err: err}
+		}
+	}
+	if opt.Signer != nil {
+		err = opt.Signer.Sign(req, body)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if opt.Auth != nil {
+		invalidateToken(opt.Auth, req, res)
+	}
+
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// cancelBody releases the context of an attempt when the response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (p RetryPolicy) retryable(res *http.Response, err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(res, err)
+	}
+	if err != nil {
+		var authErr *AuthError
+		return !errors.As(err, &authErr)
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
+
+func (p RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff > 0 {
+		return p.MaxBackoff
+	}
+	return 10 * time.Second
+}
+
+// backoff returns the delay before the retry following the given attempt. Half of the delay is
+// randomized so that clients failing together do not retry together.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	d := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	d = min(d, float64(p.maxBackoff()))
+
+	half := time.Duration(d / 2)
+	return half + rand.N(half+1)
+}
+
+// retryAfter parses the Retry-After header of a response, given in seconds or as an HTTP date
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,219 @@
+package toolkit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// errorTransport fails every request with a network error
+type errorTransport struct {
+	attempts int
+}
+
+func (e *errorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	e.attempts++
+	return nil, errors.New("connection refused")
+}
+
+func TestTools_PushJSONToRemoteContext(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	tests := []struct {
+		name             string
+		responses        []*http.Response
+		policy           RetryPolicy
+		expectedStatus   int
+		expectedAttempts int
+	}{
+		{
+			name:             "success",
+			responses:        []*http.Response{{StatusCode: http.StatusOK}},
+			policy:           policy,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "server errors",
+			responses:        []*http.Response{{StatusCode: http.StatusServiceUnavailable}, {StatusCode: http.StatusBadGateway}, {StatusCode: http.StatusAccepted}},
+			policy:           policy,
+			expectedStatus:   http.StatusAccepted,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "attempts exhausted",
+			responses:        []*http.Response{{StatusCode: http.StatusInternalServerError}, {StatusCode: http.StatusInternalServerError}, {StatusCode: http.StatusInternalServerError}},
+			policy:           policy,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "retry after",
+			responses:        []*http.Response{{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"0"}}}, {StatusCode: http.StatusOK}},
+			policy:           policy,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "retry after too long",
+			responses:        []*http.Response{{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"3600"}}}, {StatusCode: http.StatusOK}},
+			policy:           policy,
+			expectedStatus:   http.StatusTooManyRequests,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "client error",
+			responses:        []*http.Response{{StatusCode: http.StatusBadRequest}, {StatusCode: http.StatusOK}},
+			policy:           policy,
+			expectedStatus:   http.StatusBadRequest,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "no retries",
+			responses:        []*http.Response{{StatusCode: http.StatusServiceUnavailable}, {StatusCode: http.StatusOK}},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:      "custom retryable",
+			responses: []*http.Response{{StatusCode: http.StatusConflict}, {StatusCode: http.StatusOK}},
+			policy: RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, Retryable: func(res *http.Response, err error) bool {
+				return err == nil && res.StatusCode == http.StatusConflict
+			}},
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+	}
+
+	var testTools Tools
+	for _, e := range tests {
+		var attempts int
+		var keys []string
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			body, _ := io.ReadAll(req.Body)
+			if string(body) != `{"event":"created"}` {
+				t.Errorf("%s: wrong body %s", e.name, body)
+			}
+			keys = append(keys, req.Header.Get("Idempotency-Key"))
+
+			res := e.responses[attempts]
+			attempts++
+			if res.Header == nil {
+				res.Header = make(http.Header)
+			}
+			res.Body = io.NopCloser(strings.NewReader("reply"))
+			return res
+		})
+
+		res, status, err := testTools.PushJSONToRemoteContext(context.Background(), "http://someurl", map[string]string{"event": "created"}, PushOptions{Client: client, Retry: e.policy})
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+			continue
+		}
+
+		if status != e.expectedStatus || attempts != e.expectedAttempts {
+			t.Errorf("%s: expected status %d after %d attempts but got %d after %d", e.name, e.expectedStatus, e.expectedAttempts, status, attempts)
+		}
+		if body, _ := io.ReadAll(res.Body); string(body) != "reply" {
+			t.Errorf("%s: response body not readable, got %q", e.name, body)
+		}
+		res.Body.Close()
+
+		for _, key := range keys {
+			if e.policy.MaxAttempts > 1 && (key == "" || key != keys[0]) {
+				t.Errorf("%s: expected the same idempotency key on every attempt but got %v", e.name, keys)
+				break
+			}
+		}
+	}
+}
+
+func TestTools_PushJSONToRemoteContext_Errors(t *testing.T) {
+	var testTools Tools
+
+	transport := &errorTransport{}
+	_, _, err := testTools.PushJSONToRemoteContext(context.Background(), "http://someurl", nil, PushOptions{
+		Client: &http.Client{Transport: transport},
+		Retry:  RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed after 3 attempts") {
+		t.Errorf("expected error after 3 attempts but got %v", err)
+	}
+	if transport.attempts != 3 {
+		t.Errorf("expected 3 attempts but got %d", transport.attempts)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	transport = &errorTransport{}
+	_, _, err = testTools.PushJSONToRemoteContext(ctx, "http://someurl", nil, PushOptions{
+		Client: &http.Client{Transport: transport},
+		Retry:  RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour},
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || transport.attempts != 1 {
+		t.Errorf("expected deadline exceeded after 1 attempt but got %v after %d", err, transport.attempts)
+	}
+
+	_, _, err = testTools.PushJSONToRemoteContext(context.Background(), "http://someurl", func() {})
+	if err == nil {
+		t.Error("expected error for data that can not be encoded")
+	}
+}
+
+func TestTools_PushJSONToRemoteContext_AttemptTimeout(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			<-release
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	var testTools Tools
+	res, status, err := testTools.PushJSONToRemoteContext(context.Background(), server.URL, nil, PushOptions{
+		Retry:          RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+		AttemptTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if status != http.StatusCreated || requests.Load() != 2 {
+		t.Errorf("expected %d after 2 requests but got %d after %d", http.StatusCreated, status, requests.Load())
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{attempt: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{attempt: 2, min: 100 * time.Millisecond, max: 200 * time.Millisecond},
+		{attempt: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{attempt: 10, min: 500 * time.Millisecond, max: time.Second},
+	}
+
+	for _, e := range tests {
+		for i := 0; i < 20; i++ {
+			d := policy.backoff(e.attempt)
+			if d < e.min || d > e.max {
+				t.Errorf("attempt %d: backoff %s not within [%s, %s]", e.attempt, d, e.min, e.max)
+			}
+		}
+	}
+}
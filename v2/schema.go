@@ -0,0 +1,521 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSchemaErrors caps the number of violations reported for a single body
+const maxSchemaErrors = 20
+
+// unsupportedSchemaKeywords are assertions of draft 2020-12 that Schema does not implement.
+// CompileSchema rejects them rather than ignoring them, so a published schema can never be
+// enforced more loosely than it reads.
+var unsupportedSchemaKeywords = []string{
+	"allOf", "anyOf", "oneOf", "not", "if", "then", "else",
+	"dependentRequired", "dependentSchemas", "patternProperties", "propertyNames",
+	"minProperties", "maxProperties", "prefixItems", "contains", "minContains", "maxContains",
+	"uniqueItems", "multipleOf", "unevaluatedItems", "unevaluatedProperties", "$dynamicRef",
+}
+
+// Schema is a compiled JSON Schema. It supports the draft 2020-12 keywords type, properties,
+// required, additionalProperties, items, enum, const, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, minLength, maxLength, minItems, maxItems and local $ref
+// (for example "#/$defs/address"). Patterns use Go regular expression syntax.
+type Schema struct {
+	always *bool // set for the boolean schemas true and false
+	ref    *Schema
+
+	types      []string
+	enum       []interface{}
+	properties map[string]*Schema
+	required   []string
+	additional *Schema
+	items      *Schema
+	pattern    *regexp.Regexp
+
+	minimum, maximum                   *schemaBound
+	exclusiveMinimum, exclusiveMaximum *schemaBound
+	minLength, maxLength               *int
+	minItems, maxItems                 *int
+}
+
+// schemaBound is a numeric keyword, kept as written in the schema for error messages
+type schemaBound struct {
+	value *big.Rat
+	text  string
+}
+
+// CompileSchema parses a JSON Schema document
+func CompileSchema(schema []byte) (*Schema, error) {
+	root, err := unmarshalPatchJSON(schema)
+	if err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+
+	c := schemaCompiler{root: root, compiled: make(map[string]*Schema)}
+	s, err := c.compile(root, "")
+	if err != nil {
+		return nil, err
+	}
+	err = c.checkRefCycles()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// MustCompileSchema is like CompileSchema but panics if the schema can not be compiled. It
+// simplifies the initialization of package level schema variables.
+func MustCompileSchema(schema []byte) *Schema {
+	s, err := CompileSchema(schema)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+type schemaCompiler struct {
+	root     interface{}
+	compiled map[string]*Schema // keyed by the JSON Pointer of the schema in the document
+}
+
+func (c *schemaCompiler) compile(node interface{}, pointer string) (*Schema, error) {
+	s := &Schema{}
+	c.compiled[pointer] = s
+
+	if b, ok := node.(bool); ok {
+		s.always = &b
+		return s, nil
+	}
+	keywords, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema at %q must be an object or a boolean", pointer)
+	}
+
+	for _, keyword := range unsupportedSchemaKeywords {
+		if _, ok := keywords[keyword]; ok {
+			return nil, fmt.Errorf("schema at %q uses unsupported keyword %q", pointer, keyword)
+		}
+	}
+
+	invalid := func(keyword string) error {
+		return fmt.Errorf("schema at %q has an invalid %q", pointer, keyword)
+	}
+
+	for keyword, value := range keywords {
+		var err error
+		switch keyword {
+		case "$ref":
+			ref, ok := value.(string)
+			if !ok {
+				return nil, invalid(keyword)
+			}
+			s.ref, err = c.resolve(ref)
+
+		case "type":
+			switch v := value.(type) {
+			case string:
+				s.types = []string{v}
+			case []interface{}:
+				for _, t := range v {
+					name, ok := t.(string)
+					if !ok {
+						return nil, invalid(keyword)
+					}
+					s.types = append(s.types, name)
+				}
+			default:
+				return nil, invalid(keyword)
+			}
+			for _, name := range s.types {
+				if !slices.Contains([]string{"null", "boolean", "object", "array", "number", "integer", "string"}, name) {
+					return nil, fmt.Errorf("schema at %q has unknown type %q", pointer, name)
+				}
+			}
+
+		case "enum":
+			values, ok := value.([]interface{})
+			if !ok {
+				return nil, invalid(keyword)
+			}
+			s.enum = values
+
+		case "const":
+			s.enum = []interface{}{value}
+
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, invalid(keyword)
+			}
+			s.properties = make(map[string]*Schema, len(properties))
+			for name, property := range properties {
+				s.properties[name], err = c.compile(property, pointer+"/properties/"+escapePointerToken(name))
+				if err != nil {
+					return nil, err
+				}
+			}
+
+		case "required":
+			names, ok := value.([]interface{})
+			if !ok {
+				return nil, invalid(keyword)
+			}
+			for _, n := range names {
+				name, ok := n.(string)
+				if !ok {
+					return nil, invalid(keyword)
+				}
+				s.required = append(s.required, name)
+			}
+
+		case "additionalProperties":
+			s.additional, err = c.compile(value, pointer+"/additionalProperties")
+
+		case "items":
+			s.items, err = c.compile(value, pointer+"/items")
+
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				return nil, invalid(keyword)
+			}
+			s.pattern, err = regexp.Compile(pattern)
+
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			n, ok := value.(json.Number)
+			if !ok {
+				return nil, invalid(keyword)
+			}
+			rat, ok := parseNumber(n)
+			if !ok {
+				return nil, invalid(keyword)
+			}
+			bound := &schemaBound{value: rat, text: n.String()}
+			switch keyword {
+			case "minimum":
+				s.minimum = bound
+			case "maximum":
+				s.maximum = bound
+			case "exclusiveMinimum":
+				s.exclusiveMinimum = bound
+			case "exclusiveMaximum":
+				s.exclusiveMaximum = bound
+			}
+
+		case "minLength", "maxLength", "minItems", "maxItems":
+			n, ok := value.(json.Number)
+			if !ok {
+				return nil, invalid(keyword)
+			}
+			i, convErr := n.Int64()
+			if convErr != nil || i < 0 {
+				return nil, invalid(keyword)
+			}
+			limit := int(i)
+			switch keyword {
+			case "minLength":
+				s.minLength = &limit
+			case "maxLength":
+				s.maxLength = &limit
+			case "minItems":
+				s.minItems = &limit
+			case "maxItems":
+				s.maxItems = &limit
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// resolve compiles the schema a local $ref points to, reusing it if it was already compiled so
+// that recursive schemas terminate
+func (c *schemaCompiler) resolve(ref string) (*Schema, error) {
+	fragment, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("schema $ref %q is not supported, only local references are", ref)
+	}
+	pointer, err := url.PathUnescape(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("schema $ref %q is invalid", ref)
+	}
+
+	if s, ok := c.compiled[pointer]; ok {
+		return s, nil
+	}
+
+	path, err := parsePointer(pointer)
+	if err != nil {
+		return nil, fmt.Errorf("schema $ref %q is invalid", ref)
+	}
+	node, err := pointerGet(c.root, path)
+	if err != nil {
+		return nil, fmt.Errorf("schema $ref %q does not exist", ref)
+	}
+	return c.compile(node, pointer)
+}
+
+// checkRefCycles rejects schemas whose $ref chain leads back to themselves, such as
+// {"$ref": "#"}, which would apply to the same value forever. A $ref reached through properties
+// or items is fine, since it applies to a nested value.
+func (c *schemaCompiler) checkRefCycles() error {
+	pointers := make([]string, 0, len(c.compiled))
+	for pointer := range c.compiled {
+		pointers = append(pointers, pointer)
+	}
+	sort.Strings(pointers)
+
+	for _, pointer := range pointers {
+		seen := make(map[*Schema]bool)
+		for s := c.compiled[pointer]; s != nil; s = s.ref {
+			if seen[s] {
+				return fmt.Errorf("schema at %q has a $ref cycle", pointer)
+			}
+			seen[s] = true
+		}
+	}
+	return nil
+}
+
+// SchemaError is a single schema violation. Path is the JSON Pointer of the offending value in
+// the body, empty for the body itself.
+type SchemaError struct {
+	Path    string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	if e.Path == "" {
+		return "body " + e.Message
+	}
+	return fmt.Sprintf("body field %q %s", e.Path, e.Message)
+}
+
+// SchemaValidationError is returned when a body does not match a Schema. At most 20 violations
+// are reported.
+type SchemaValidationError struct {
+	Errors []SchemaError
+}
+
+func (e *SchemaValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks a JSON document against the schema. It returns a *SchemaValidationError if
+// the document is valid JSON but does not match.
+func (s *Schema) Validate(doc []byte) error {
+	v, err := unmarshalPatchJSON(doc)
+	if err != nil {
+		return err
+	}
+	return s.validateValue(v)
+}
+
+// validateValue checks a value decoded by unmarshalPatchJSON against the schema
+func (s *Schema) validateValue(v interface{}) error {
+	var errs []SchemaError
+	s.validate(v, "", &errs)
+	if len(errs) > 0 {
+		return &SchemaValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (s *Schema) validate(v interface{}, path string, errs *[]SchemaError) {
+	report := func(format string, args ...interface{}) {
+		*errs = appendSchemaError(*errs, path, fmt.Sprintf(format, args...))
+	}
+
+	if s.always != nil {
+		if !*s.always {
+			report("is not allowed")
+		}
+		return
+	}
+	if s.ref != nil {
+		s.ref.validate(v, path, errs)
+	}
+
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(name string) bool { return schemaTypeMatches(name, v) }) {
+		report("must be of type %s", strings.Join(s.types, " or "))
+		return
+	}
+
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(e interface{}) bool { return equalJSON(e, v) }) {
+		allowed := make([]string, len(s.enum))
+		for i, e := range s.enum {
+			out, _ := json.Marshal(e)
+			allowed[i] = string(out)
+		}
+		report("must be one of %s", strings.Join(allowed, ", "))
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := value[name]; !ok {
+				*errs = appendSchemaError(*errs, path+"/"+escapePointerToken(name), "is required")
+			}
+		}
+
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			childPath := path + "/" + escapePointerToken(name)
+			if property, ok := s.properties[name]; ok {
+				property.validate(value[name], childPath, errs)
+			} else if s.additional != nil {
+				s.additional.validate(value[name], childPath, errs)
+			}
+		}
+
+	case []interface{}:
+		if s.minItems != nil && len(value) < *s.minItems {
+			report("must contain at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(value) > *s.maxItems {
+			report("must contain at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range value {
+				s.items.validate(item, fmt.Sprintf("%s/%d", path, i), errs)
+			}
+		}
+
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.minLength != nil && length < *s.minLength {
+			report("must be at least %d characters long", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			report("must be at most %d characters long", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			report("must match pattern %q", s.pattern.String())
+		}
+
+	case json.Number:
+		if s.minimum == nil && s.maximum == nil && s.exclusiveMinimum == nil && s.exclusiveMaximum == nil {
+			return
+		}
+		n, ok := parseNumber(value)
+		if !ok {
+			report("is out of the supported range")
+			return
+		}
+		if s.minimum != nil && n.Cmp(s.minimum.value) < 0 {
+			report("must be greater than or equal to %s", s.minimum.text)
+		}
+		if s.exclusiveMinimum != nil && n.Cmp(s.exclusiveMinimum.value) <= 0 {
+			report("must be greater than %s", s.exclusiveMinimum.text)
+		}
+		if s.maximum != nil && n.Cmp(s.maximum.value) > 0 {
+			report("must be less than or equal to %s", s.maximum.text)
+		}
+		if s.exclusiveMaximum != nil && n.Cmp(s.exclusiveMaximum.value) >= 0 {
+			report("must be less than %s", s.exclusiveMaximum.text)
+		}
+	}
+}
+
+func appendSchemaError(errs []SchemaError, path, message string) []SchemaError {
+	if len(errs) < maxSchemaErrors {
+		errs = append(errs, SchemaError{Path: path, Message: message})
+	}
+	return errs
+}
+
+// schemaTypeMatches reports whether a value decoded with UseNumber has the JSON Schema type name
+func schemaTypeMatches(name string, v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return name == "null"
+	case bool:
+		return name == "boolean"
+	case map[string]interface{}:
+		return name == "object"
+	case []interface{}:
+		return name == "array"
+	case string:
+		return name == "string"
+	case json.Number:
+		if name == "number" {
+			return true
+		}
+		n, ok := parseNumber(v)
+		return name == "integer" && ok && n.IsInt()
+	}
+	return false
+}
+
+// Numbers with more digits or a larger exponent are not compared exactly. math/big expands the
+// exponent, so parsing 1e999999 sent by a client would build a number of a million digits.
+const (
+	maxNumberDigits   = 1000
+	maxNumberExponent = 1000
+)
+
+// parseNumber parses a JSON number exactly, failing for numbers over maxNumberDigits or
+// maxNumberExponent
+func parseNumber(n json.Number) (*big.Rat, bool) {
+	s := n.String()
+	mantissa, exponent := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa, exponent = s[:i], s[i+1:]
+	}
+	if len(mantissa) > maxNumberDigits {
+		return nil, false
+	}
+	if exponent != "" {
+		e, err := strconv.Atoi(exponent)
+		if err != nil || e > maxNumberExponent || e < -maxNumberExponent {
+			return nil, false
+		}
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// validateJSONBody parses body and checks it against schema, reporting malformed bodies like
+// decodeJSON does
+func validateJSONBody(body []byte, schema *Schema) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v interface{}
+	err := dec.Decode(&v)
+	if err != nil {
+		return jsonDecodeError(err)
+	}
+
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		return errors.New("body contains more than one json value")
+	}
+
+	return schema.validateValue(v)
+}
@@ -0,0 +1,174 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSchema = MustCompileSchema([]byte(`{
+	"type": "object",
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 2, "maxLength": 10, "pattern": "^[A-Za-z]+$"},
+		"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+		"role": {"enum": ["admin", "user"]},
+		"score": {"type": ["number", "null"], "maximum": 1.5, "minimum": -0.1},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+		"address": {"$ref": "#/$defs/address"},
+		"manager": {"$ref": "#"}
+	},
+	"$defs": {
+		"address": {
+			"type": "object",
+			"required": ["city"],
+			"properties": {"city": {"type": "string"}},
+			"additionalProperties": {"type": "string"}
+		}
+	}
+}`))
+
+var schemaValidateTests = []struct {
+	name   string
+	body   string
+	errors []SchemaError
+}{
+	{name: "valid", body: `{"name": "John", "age": 42, "role": "admin", "score": null, "tags": ["a"], "address": {"city": "Dhaka", "zip": "1207"}}`},
+	{name: "big integer", body: `{"name": "John", "age": 149.0}`},
+	{name: "recursive", body: `{"name": "John", "age": 42, "manager": {"name": "Jane", "age": 50}}`},
+	{name: "wrong root type", body: `[]`, errors: []SchemaError{{Path: "", Message: "must be of type object"}}},
+	{name: "missing required", body: `{"name": "John"}`, errors: []SchemaError{{Path: "/age", Message: "is required"}}},
+	{name: "not an integer", body: `{"name": "John", "age": 4.5}`, errors: []SchemaError{{Path: "/age", Message: "must be of type integer"}}},
+	{name: "out of range", body: `{"name": "John", "age": 150}`, errors: []SchemaError{{Path: "/age", Message: "must be less than 150"}}},
+	{name: "below minimum", body: `{"name": "John", "age": -1}`, errors: []SchemaError{{Path: "/age", Message: "must be greater than or equal to 0"}}},
+	{name: "decimal maximum", body: `{"name": "John", "age": 1, "score": 1.6}`, errors: []SchemaError{{Path: "/score", Message: "must be less than or equal to 1.5"}}},
+	{name: "huge exponent", body: `{"name": "John", "age": 1e999999, "score": -1e999999}`, errors: []SchemaError{{Path: "/age", Message: "must be of type integer"}, {Path: "/score", Message: "is out of the supported range"}}},
+	{name: "decimal minimum", body: `{"name": "John", "age": 1, "score": -0.2}`, errors: []SchemaError{{Path: "/score", Message: "must be greater than or equal to -0.1"}}},
+	{name: "enum", body: `{"name": "John", "age": 1, "role": "root"}`, errors: []SchemaError{{Path: "/role", Message: `must be one of "admin", "user"`}}},
+	{name: "string limits", body: `{"name": "J", "age": 1}`, errors: []SchemaError{{Path: "/name", Message: "must be at least 2 characters long"}}},
+	{name: "pattern", body: `{"name": "John1", "age": 1}`, errors: []SchemaError{{Path: "/name", Message: `must match pattern "^[A-Za-z]+$"`}}},
+	{name: "additional property", body: `{"name": "John", "age": 1, "admin": true}`, errors: []SchemaError{{Path: "/admin", Message: "is not allowed"}}},
+	{
+		name: "nested",
+		body: `{"name": "John", "age": 1, "tags": ["a", 2, "c"], "address": {"zip": 1207}, "manager": {"name": "Jane"}}`,
+		errors: []SchemaError{
+			{Path: "/address/city", Message: "is required"},
+			{Path: "/address/zip", Message: "must be of type string"},
+			{Path: "/manager/age", Message: "is required"},
+			{Path: "/tags", Message: "must contain at most 2 items"},
+			{Path: "/tags/1", Message: "must be of type string"},
+		},
+	},
+}
+
+func TestSchema_Validate(t *testing.T) {
+	for _, e := range schemaValidateTests {
+		err := testSchema.Validate([]byte(e.body))
+		if len(e.errors) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", e.name, err)
+			}
+			continue
+		}
+
+		var validationError *SchemaValidationError
+		if !errors.As(err, &validationError) {
+			t.Errorf("%s: expected validation error but got %v", e.name, err)
+			continue
+		}
+		if len(validationError.Errors) != len(e.errors) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.errors, validationError.Errors)
+			continue
+		}
+		for i := range e.errors {
+			if validationError.Errors[i] != e.errors[i] {
+				t.Errorf("%s: expected %v but got %v", e.name, e.errors[i], validationError.Errors[i])
+			}
+		}
+	}
+}
+
+func TestCompileSchema_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{name: "not json", schema: `{`},
+		{name: "not an object", schema: `1`},
+		{name: "unknown type", schema: `{"type": "float"}`},
+		{name: "unsupported keyword", schema: `{"oneOf": [{"type": "string"}]}`},
+		{name: "remote ref", schema: `{"$ref": "https://example.com/schema.json"}`},
+		{name: "missing ref", schema: `{"$ref": "#/$defs/missing"}`},
+		{name: "bad pattern", schema: `{"pattern": "("}`},
+		{name: "negative length", schema: `{"minLength": -1}`},
+		{name: "nested error", schema: `{"properties": {"a": {"type": 1}}}`},
+		{name: "self ref", schema: `{"$ref": "#"}`},
+		{name: "ref cycle", schema: `{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}, "properties": {"x": {"$ref": "#/$defs/a"}}}`},
+	}
+
+	for _, e := range tests {
+		_, err := CompileSchema([]byte(e.schema))
+		if err == nil {
+			t.Errorf("%s: error expected but none received", e.name)
+		}
+	}
+}
+
+func TestTools_ReadJSON_SchemaLargeNumbers(t *testing.T) {
+	testTools := Tools{MaxJSONSize: 1 << 20}
+	schema := MustCompileSchema([]byte(`{"type": "array", "items": {"type": "integer"}}`))
+	body := "[" + strings.Repeat("1e999999,", 2000) + "1]"
+
+	start := time.Now()
+	var v []float64
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &v, schema)
+	if err == nil || !strings.Contains(err.Error(), `body field "/0" must be of type integer`) {
+		t.Errorf("expected a validation error but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("validating large exponents took %s", elapsed)
+	}
+}
+
+func TestTools_ReadJSON_Schema(t *testing.T) {
+	var testTools Tools
+
+	tests := []struct {
+		name    string
+		body    string
+		message string
+	}{
+		{name: "valid", body: `{"name": "John", "age": 42}`},
+		{name: "schema violation", body: `{"name": "John", "age": "42"}`, message: `body field "/age" must be of type integer`},
+		{name: "two violations", body: `{"name": "John1"}`, message: `body field "/age" is required; body field "/name" must match pattern "^[A-Za-z]+$"`},
+		{name: "badly formed", body: `{"name": }`, message: "body contains badly-formed JSON (at character 10)"},
+		{name: "two values", body: `{"name": "John", "age": 42}{}`, message: "body contains more than one json value"},
+		{name: "empty", body: ``, message: "body must not be empty"},
+	}
+
+	for _, e := range tests {
+		var person struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		}
+		req := httptest.NewRequest("POST", "/", strings.NewReader(e.body))
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &person, testSchema)
+
+		if e.message == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", e.name, err)
+			}
+			if person.Name != "John" || person.Age != 42 {
+				t.Errorf("%s: body not decoded %+v", e.name, person)
+			}
+			continue
+		}
+		if err == nil || err.Error() != e.message {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.message, err)
+		}
+	}
+}
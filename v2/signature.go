@@ -0,0 +1,261 @@
+package toolkit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSignatureHeader = "X-Signature"
+	defaultTimestampHeader = "X-Signature-Timestamp"
+	defaultNonceHeader     = "X-Signature-Nonce"
+	defaultSigningKeyID    = "default"
+)
+
+// SigningKey is a shared secret used to sign requests. The ID is sent along with the signature
+// so that keys can be rotated: sign with the old and the new key until every receiver knows the
+// new one, then drop the old key.
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// Signer signs outgoing requests with HMAC-SHA256 over the timestamp, the nonce and the body,
+// separated by dots. The signature header holds one id=hex pair per key, separated by commas,
+// the timestamp header the Unix time of signing and the nonce header a random value unique to
+// the signature. Set PushOptions.Signer to sign webhooks sent with PushJSONToRemoteContext;
+// every retry is signed again with a new nonce.
+type Signer struct {
+	Keys []SigningKey
+	// SignatureHeader, TimestampHeader and NonceHeader default to X-Signature,
+	// X-Signature-Timestamp and X-Signature-Nonce
+	SignatureHeader string
+	TimestampHeader string
+	NonceHeader     string
+
+	now func() time.Time
+}
+
+// NewSigner returns a signer using keys
+func NewSigner(keys ...SigningKey) *Signer {
+	return &Signer{Keys: keys}
+}
+
+// Sign sets the signature, timestamp and nonce headers of req for body, which must be the exact
+// bytes sent as the request body
+func (s *Signer) Sign(req *http.Request, body []byte) error {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	nonce, err := randomString(24, AlphabetAlphanumeric)
+	if err != nil {
+		return err
+	}
+
+	signatures := make([]string, len(s.Keys))
+	for i, key := range s.Keys {
+		id := key.ID
+		if id == "" {
+			id = defaultSigningKeyID
+		}
+		signatures[i] = id + "=" + computeSignature(key.Secret, timestamp, nonce, body)
+	}
+
+	req.Header.Set(headerOrDefault(s.TimestampHeader, defaultTimestampHeader), timestamp)
+	req.Header.Set(headerOrDefault(s.NonceHeader, defaultNonceHeader), nonce)
+	req.Header.Set(headerOrDefault(s.SignatureHeader, defaultSignatureHeader), strings.Join(signatures, ","))
+	return nil
+}
+
+func computeSignature(secret []byte, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func headerOrDefault(header, fallback string) string {
+	if header != "" {
+		return header
+	}
+	return fallback
+}
+
+// NonceStore remembers the requests a Verifier has accepted, so that a captured request can not
+// be replayed while its timestamp is still within the tolerance
+type NonceStore interface {
+	// Use records nonce until expires and reports whether it was unused
+	Use(nonce string, expires time.Time) (bool, error)
+}
+
+// MemoryNonceStore is a NonceStore for a single process
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastPrune time.Time
+}
+
+// NewMemoryNonceStore returns an empty MemoryNonceStore
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+func (m *MemoryNonceStore) Use(nonce string, expires time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastPrune) > time.Minute {
+		for n, e := range m.nonces {
+			if now.After(e) {
+				delete(m.nonces, n)
+			}
+		}
+		m.lastPrune = now
+	}
+
+	if e, ok := m.nonces[nonce]; ok && !now.After(e) {
+		return false, nil
+	}
+	m.nonces[nonce] = expires
+	return true, nil
+}
+
+// Verifier checks requests signed by a Signer with the same keys. Signatures made with a key
+// it does not know are ignored, so one of the signatures must be made with a known key.
+type Verifier struct {
+	Keys []SigningKey
+	// SignatureHeader, TimestampHeader and NonceHeader default to X-Signature,
+	// X-Signature-Timestamp and X-Signature-Nonce
+	SignatureHeader string
+	TimestampHeader string
+	NonceHeader     string
+	// Tolerance is the maximum age of a signature, and how far in the future its timestamp may
+	// be to allow for clock skew. It defaults to five minutes.
+	Tolerance time.Duration
+	// Nonces rejects replayed requests when set, by remembering the signed nonce of every
+	// accepted request
+	Nonces NonceStore
+
+	t   *Tools
+	now func() time.Time
+}
+
+// NewVerifier returns a verifier accepting signatures made with any of keys
+func NewVerifier(keys ...SigningKey) *Verifier {
+	return &Verifier{Keys: keys}
+}
+
+// NewVerifier returns a verifier accepting signatures made with any of keys whose Middleware
+// writes its errors with the settings of t
+func (t *Tools) NewVerifier(keys ...SigningKey) *Verifier {
+	return &Verifier{Keys: keys, t: t}
+}
+
+// Verify checks the signature of a request with the given body
+func (v *Verifier) Verify(r *http.Request, body []byte) error {
+	timestamp := r.Header.Get(headerOrDefault(v.TimestampHeader, defaultTimestampHeader))
+	nonce := r.Header.Get(headerOrDefault(v.NonceHeader, defaultNonceHeader))
+	header := r.Header.Get(headerOrDefault(v.SignatureHeader, defaultSignatureHeader))
+	if timestamp == "" || nonce == "" || header == "" {
+		return errors.New("request is not signed")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("request signature timestamp is invalid")
+	}
+	signedAt := time.Unix(unix, 0)
+
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	tolerance := v.Tolerance
+	if tolerance <= 0 {
+		tolerance = 5 * time.Minute
+	}
+	if age := now().Sub(signedAt); age > tolerance || age < -tolerance {
+		return errors.New("request signature timestamp is outside the tolerance")
+	}
+
+	matched := false
+	for _, pair := range strings.Split(header, ",") {
+		id, signature, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		for _, key := range v.Keys {
+			keyID := key.ID
+			if keyID == "" {
+				keyID = defaultSigningKeyID
+			}
+			expected := computeSignature(key.Secret, timestamp, nonce, body)
+			if keyID == id && hmac.Equal([]byte(expected), []byte(signature)) {
+				matched = true
+			}
+		}
+	}
+	if !matched {
+		return errors.New("request signature is invalid")
+	}
+
+	if v.Nonces != nil {
+		// the nonce is covered by every signature, so a replay can not change it to look like a
+		// new request
+		fresh, err := v.Nonces.Use(nonce, signedAt.Add(tolerance))
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return errors.New("request has already been received")
+		}
+	}
+
+	return nil
+}
+
+// Middleware rejects requests without a valid signature with 401 Unauthorized. The body, limited
+// to MaxJSONSize, is read to verify the signature and restored for next.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	t := v.t
+	if t == nil {
+		t = &Tools{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(t.maxJSONSize())))
+		if err != nil {
+			status := http.StatusBadRequest
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			_ = t.ErrorJSON(w, jsonDecodeError(err), status)
+			return
+		}
+
+		err = v.Verify(r, body)
+		if err != nil {
+			_ = t.ErrorJSON(w, err, http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
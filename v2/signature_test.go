@@ -0,0 +1,220 @@
+package toolkit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVerifier_Middleware(t *testing.T) {
+	var testTools Tools
+	verifier := testTools.NewVerifier(SigningKey{ID: "2024", Secret: []byte("new secret")})
+	verifier.Nonces = NewMemoryNonceStore()
+
+	var received string
+	server := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	defer server.Close()
+
+	// during a rotation the sender signs with the old and the new key
+	signer := NewSigner(SigningKey{ID: "2023", Secret: []byte("old secret")}, SigningKey{ID: "2024", Secret: []byte("new secret")})
+	res, status, err := testTools.PushJSONToRemoteContext(context.Background(), server.URL, map[string]string{"event": "paid"}, PushOptions{Signer: signer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if status != http.StatusNoContent || received != `{"event":"paid"}` {
+		t.Errorf("signed request rejected with %d, received %q", status, received)
+	}
+
+	// only the old key
+	signer = NewSigner(SigningKey{ID: "2023", Secret: []byte("old secret")})
+	res, status, err = testTools.PushJSONToRemoteContext(context.Background(), server.URL, map[string]string{"event": "paid"}, PushOptions{Signer: signer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if status != http.StatusUnauthorized || !strings.Contains(string(body), "request signature is invalid") {
+		t.Errorf("expected 401 but got %d %s", status, body)
+	}
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	var testTools Tools
+	now := time.Unix(1700000000, 0)
+	key := SigningKey{ID: "k1", Secret: []byte("secret")}
+
+	signed := func(signer *Signer, body string, signedAt time.Time) *http.Request {
+		signer.now = func() time.Time { return signedAt }
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		signer.Sign(req, []byte(body))
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		body    string
+		message string
+	}{
+		{name: "valid", req: signed(NewSigner(key), "{}", now), body: "{}"},
+		{name: "clock skew", req: signed(NewSigner(key), "{}", now.Add(time.Minute)), body: "{}"},
+		{name: "default key id", req: signed(NewSigner(SigningKey{Secret: []byte("other")}), "{}", now), body: "{}"},
+		{name: "not signed", req: httptest.NewRequest("POST", "/", nil), body: "{}", message: "request is not signed"},
+		{name: "no nonce", req: withoutHeader(signed(NewSigner(key), "{}", now), "X-Signature-Nonce"), body: "{}", message: "request is not signed"},
+		{name: "tampered body", req: signed(NewSigner(key), "{}", now), body: `{"admin":true}`, message: "request signature is invalid"},
+		{name: "wrong secret", req: signed(NewSigner(SigningKey{ID: "k1", Secret: []byte("guess")}), "{}", now), body: "{}", message: "request signature is invalid"},
+		{name: "unknown key", req: signed(NewSigner(SigningKey{ID: "k9", Secret: []byte("secret")}), "{}", now), body: "{}", message: "request signature is invalid"},
+		{name: "expired", req: signed(NewSigner(key), "{}", now.Add(-10*time.Minute)), body: "{}", message: "request signature timestamp is outside the tolerance"},
+		{name: "future", req: signed(NewSigner(key), "{}", now.Add(10*time.Minute)), body: "{}", message: "request signature timestamp is outside the tolerance"},
+	}
+
+	for _, e := range tests {
+		verifier := testTools.NewVerifier(key, SigningKey{Secret: []byte("other")})
+		verifier.now = func() time.Time { return now }
+
+		err := verifier.Verify(e.req, []byte(e.body))
+		if e.message == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", e.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != e.message {
+			t.Errorf("%s: expected error %q but got %v", e.name, e.message, err)
+		}
+	}
+}
+
+func withoutHeader(req *http.Request, header string) *http.Request {
+	req.Header.Del(header)
+	return req
+}
+
+func TestVerifier_Replay(t *testing.T) {
+	var testTools Tools
+	key := SigningKey{ID: "k1", Secret: []byte("secret")}
+
+	signer := NewSigner(key)
+	signer.SignatureHeader = "X-Hook-Signature"
+	signer.TimestampHeader = "X-Hook-Time"
+	signer.NonceHeader = "X-Hook-Nonce"
+	req := httptest.NewRequest("POST", "/", nil)
+	signer.Sign(req, []byte("{}"))
+
+	verifier := testTools.NewVerifier(key)
+	verifier.SignatureHeader = "X-Hook-Signature"
+	verifier.TimestampHeader = "X-Hook-Time"
+	verifier.NonceHeader = "X-Hook-Nonce"
+	verifier.Nonces = NewMemoryNonceStore()
+
+	if err := verifier.Verify(req, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	err := verifier.Verify(req, []byte("{}"))
+	if err == nil || err.Error() != "request has already been received" {
+		t.Errorf("expected replay to be rejected but got %v", err)
+	}
+
+	// the nonce is signed
+	req.Header.Set("X-Hook-Nonce", "other")
+	err = verifier.Verify(req, []byte("{}"))
+	if err == nil || err.Error() != "request signature is invalid" {
+		t.Errorf("expected changed nonce to be rejected but got %v", err)
+	}
+
+	// the same body signed again in the same second is a new request
+	_ = signer.Sign(req, []byte("{}"))
+	if err = verifier.Verify(req, []byte("{}")); err != nil {
+		t.Errorf("expected a new signature to be accepted but got %v", err)
+	}
+}
+
+func TestVerifier_Retry(t *testing.T) {
+	var testTools Tools
+	key := SigningKey{ID: "k1", Secret: []byte("secret")}
+	verifier := NewVerifier(key)
+	verifier.Nonces = NewMemoryNonceStore()
+
+	var calls int32
+	server := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	defer server.Close()
+
+	// the retry follows within the same second and is signed again
+	res, status, err := testTools.PushJSONToRemoteContext(context.Background(), server.URL, map[string]string{"event": "paid"}, PushOptions{
+		Signer: NewSigner(key),
+		Retry:  RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if status != http.StatusNoContent || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("expected the retry to be accepted but got %d after %d calls", status, calls)
+	}
+}
+
+func TestVerifier_RotatedReplay(t *testing.T) {
+	oldKey := SigningKey{ID: "2023", Secret: []byte("old secret")}
+	newKey := SigningKey{ID: "2024", Secret: []byte("new secret")}
+
+	req := httptest.NewRequest("POST", "/", nil)
+	NewSigner(oldKey, newKey).Sign(req, []byte("{}"))
+
+	verifier := NewVerifier(oldKey, newKey)
+	verifier.Nonces = NewMemoryNonceStore()
+	if err := verifier.Verify(req, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	// replaying the captured request with only one of its signatures
+	header := req.Header.Get("X-Signature")
+	for _, pair := range strings.Split(header, ",") {
+		req.Header.Set("X-Signature", pair)
+		err := verifier.Verify(req, []byte("{}"))
+		if err == nil || err.Error() != "request has already been received" {
+			t.Errorf("expected replay of %s to be rejected but got %v", pair, err)
+		}
+	}
+}
+
+func TestVerifier_Literal(t *testing.T) {
+	key := SigningKey{Secret: []byte("secret")}
+	verifier := &Verifier{Keys: []SigningKey{key}}
+
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader("{}")))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 but got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("{}"))
+	NewSigner(key).Sign(req, []byte("{}"))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204 but got %d", rr.Code)
+	}
+}
@@ -0,0 +1,214 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSEEvent is a single server-sent event. Data is JSON encoded like the payload of WriteJSON.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Retry time.Duration
+	Data  interface{}
+}
+
+// SSEBuffer keeps recently sent events, so a client reconnecting with a Last-Event-ID header can
+// be sent the events it missed.
+type SSEBuffer interface {
+	// Add stores an event that was sent to the clients
+	Add(event SSEEvent)
+	// Since returns the events stored after the event with the given id. When the id is unknown
+	// all stored events are returned.
+	Since(id string) []SSEEvent
+}
+
+// SSERingBuffer is an in-memory SSEBuffer holding the last Size events. It is safe for
+// concurrent use by several streams.
+type SSERingBuffer struct {
+	mu     sync.Mutex
+	size   int
+	events []SSEEvent
+}
+
+// NewSSERingBuffer returns a buffer that holds up to size events
+func NewSSERingBuffer(size int) *SSERingBuffer {
+	return &SSERingBuffer{size: size}
+}
+
+func (b *SSERingBuffer) Add(event SSEEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.events = append(b.events, event)
+	if len(b.events) > b.size {
+		b.events = append([]SSEEvent(nil), b.events[len(b.events)-b.size:]...)
+	}
+}
+
+func (b *SSERingBuffer) Since(id string) []SSEEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := len(b.events) - 1; i >= 0; i-- {
+		if b.events[i].ID == id {
+			return append([]SSEEvent(nil), b.events[i+1:]...)
+		}
+	}
+	return append([]SSEEvent(nil), b.events...)
+}
+
+// SSEStream writes server-sent events to a client. It stops accepting events once the request
+// context is canceled. Handlers must call Close before returning.
+type SSEStream struct {
+	t      *Tools
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	buffer SSEBuffer
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+}
+
+// NewSSEStream sets the event stream headers and flushes them to the client. When a buffer is
+// given every event with an ID is added to it, and the events the client missed are replayed if
+// the request carries a Last-Event-ID header.
+func (t *Tools) NewSSEStream(w http.ResponseWriter, r *http.Request, buffer ...SSEBuffer) (*SSEStream, error) {
+	ctx, cancel := context.WithCancel(r.Context())
+	s := &SSEStream{
+		t:      t,
+		w:      w,
+		rc:     http.NewResponseController(w),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if len(buffer) > 0 {
+		s.buffer = buffer[0]
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	err := s.rc.Flush()
+	if err != nil {
+		cancel()
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil, errors.New("response writer does not support streaming")
+		}
+		return nil, err
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if s.buffer != nil && lastEventID != "" {
+		for _, event := range s.buffer.Since(lastEventID) {
+			err = s.write(event)
+			if err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// Send writes the event to the client and flushes it
+func (s *SSEStream) Send(event SSEEvent) error {
+	err := s.write(event)
+	if err != nil {
+		return err
+	}
+
+	if s.buffer != nil && event.ID != "" {
+		s.buffer.Add(event)
+	}
+	return nil
+}
+
+// Heartbeat sends a comment line every interval to keep intermediaries from closing an idle
+// connection. It runs until the stream is closed or the request context is canceled.
+func (s *SSEStream) Heartbeat(interval time.Duration) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if s.writeRaw([]byte(": heartbeat\n\n")) != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Done returns a channel that is closed when the client goes away or the stream is closed
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Close stops the heartbeat and waits for it to finish. Events sent after Close return an error.
+func (s *SSEStream) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *SSEStream) write(event SSEEvent) error {
+	data, err := s.t.marshalCompactJSON(event.Data)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if event.ID != "" {
+		buf.WriteString("id: " + sseField(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + sseField(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+
+	return s.writeRaw(buf.Bytes())
+}
+
+func (s *SSEStream) writeRaw(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.w.Write(p)
+	if err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// sseField strips line breaks, which would otherwise end the field early
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,133 @@
+package toolkit
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTools_NewSSEStream(t *testing.T) {
+	var testTools Tools
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/events", nil)
+
+	s, err := testTools.NewSSEStream(rr, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Send(SSEEvent{ID: "1", Event: "update", Retry: 2 * time.Second, Data: map[string]string{"foo": "bar"}})
+	if err != nil {
+		t.Error(err)
+	}
+	err = s.Send(SSEEvent{Data: "plain"})
+	if err != nil {
+		t.Error(err)
+	}
+	s.Close()
+
+	if rr.Header().Get("Content-Type") != "text/event-stream" {
+		t.Error("wrong content type", rr.Header().Get("Content-Type"))
+	}
+	if rr.Header().Get("Cache-Control") != "no-cache" {
+		t.Error("wrong cache control", rr.Header().Get("Cache-Control"))
+	}
+
+	expected := "id: 1\nevent: update\nretry: 2000\ndata: {\"foo\":\"bar\"}\n\ndata: \"plain\"\n\n"
+	if rr.Body.String() != expected {
+		t.Errorf("wrong body: %q", rr.Body.String())
+	}
+
+	err = s.Send(SSEEvent{Data: "late"})
+	if err == nil {
+		t.Error("expected error when sending on a closed stream")
+	}
+}
+
+func TestTools_NewSSEStream_Replay(t *testing.T) {
+	var testTools Tools
+	buffer := NewSSERingBuffer(3)
+
+	s, err := testTools.NewSSEStream(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2", "3", "4"} {
+		err = s.Send(SSEEvent{ID: id, Data: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	s, err = testTools.NewSSEStream(rr, req, buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	expected := "id: 3\ndata: \"3\"\n\nid: 4\ndata: \"4\"\n\n"
+	if rr.Body.String() != expected {
+		t.Errorf("wrong replay: %q", rr.Body.String())
+	}
+
+	if events := buffer.Since("unknown"); len(events) != 3 || events[0].ID != "2" {
+		t.Errorf("expected the last 3 events for an unknown id but got %v", events)
+	}
+}
+
+func TestTools_NewSSEStream_Canceled(t *testing.T) {
+	var testTools Tools
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	s, err := testTools.NewSSEStream(rr, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Heartbeat(time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("stream not done after request context was canceled")
+	}
+
+	err = s.Send(SSEEvent{Data: "foo"})
+	if err == nil {
+		t.Error("expected error when sending after the client went away")
+	}
+	s.Close()
+
+	if !strings.Contains(rr.Body.String(), ": heartbeat\n\n") {
+		t.Errorf("no heartbeat sent: %q", rr.Body.String())
+	}
+}
+
+func TestTools_NewSSEStream_Encoding(t *testing.T) {
+	testTools := Tools{DisableHTMLEscape: true, JSONIndent: "  "}
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/events", nil)
+
+	s, err := testTools.NewSSEStream(rr, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Send(SSEEvent{Data: map[string]string{"html": "<b>"}})
+	if err != nil {
+		t.Error(err)
+	}
+	s.Close()
+
+	if rr.Body.String() != "data: {\"html\":\"<b>\"}\n\n" {
+		t.Errorf("wrong body: %q", rr.Body.String())
+	}
+}
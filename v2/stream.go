@@ -0,0 +1,169 @@
+package toolkit
+
+import (
+	"errors"
+	"iter"
+	"net/http"
+)
+
+// StreamJSON writes data like WriteJSON through an encoder on w. The status and headers are
+// sent with the first encoded bytes, so if encoding fails before anything was written the
+// handler can still respond with ErrorJSON. It does not save memory for large values: the
+// encoder of encoding/json, the default JSONEngine, encodes the whole value into a buffer
+// before writing it. Use NewJSONArrayWriter, WriteJSONSeq or WriteJSONChan to send a large
+// array element by element instead.
+func (t *Tools) StreamJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
+	out := &streamWriter{w: w, status: status, headers: headers, contentType: "application/json"}
+
+	engine := t.JSONEngine
+	if engine == nil {
+		engine = stdJSONEngine{}
+	}
+	enc := engine.NewEncoder(out)
+	enc.SetEscapeHTML(!t.DisableHTMLEscape)
+	if t.JSONIndent != "" {
+		enc.SetIndent("", t.JSONIndent)
+	}
+
+	return enc.Encode(t.successPayload(status, data))
+}
+
+// streamWriter sends the status and headers of a response with its first write
+type streamWriter struct {
+	w           http.ResponseWriter
+	status      int
+	headers     []http.Header
+	contentType string
+	wroteHeader bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.writeHeader()
+	return s.w.Write(p)
+}
+
+func (s *streamWriter) writeHeader() {
+	if s.wroteHeader {
+		return
+	}
+	s.wroteHeader = true
+
+	if len(s.headers) > 0 {
+		for key, value := range s.headers[0] {
+			s.w.Header()[key] = value
+		}
+	}
+
+	s.w.Header().Set("Content-Type", s.contentType)
+	s.w.WriteHeader(s.status)
+}
+
+func (s *streamWriter) flush() error {
+	err := http.NewResponseController(s.w).Flush()
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
+// JSONArrayWriter writes a JSON array to a ResponseWriter one element at a time, flushing after
+// every element. The status and headers are sent with the first element, or by Close when the
+// array is empty. Once an element fails to encode or write, the writer stops: later calls
+// return the same error and Close leaves the array unterminated, so clients can tell the
+// response is incomplete.
+type JSONArrayWriter struct {
+	t      *Tools
+	out    *streamWriter
+	count  int
+	closed bool
+	err    error
+}
+
+// NewJSONArrayWriter returns a writer that streams array elements to w
+func (t *Tools) NewJSONArrayWriter(w http.ResponseWriter, status int, headers ...http.Header) *JSONArrayWriter {
+	return &JSONArrayWriter{
+		t:   t,
+		out: &streamWriter{w: w, status: status, headers: headers, contentType: "application/json"},
+	}
+}
+
+// Write encodes data as the next element of the array and flushes it to the client
+func (a *JSONArrayWriter) Write(data interface{}) error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.closed {
+		return errors.New("json array writer is closed")
+	}
+
+	out, err := a.t.marshalJSON(data, nil)
+	if err != nil {
+		a.err = err
+		return err
+	}
+
+	separator := byte(',')
+	if a.count == 0 {
+		separator = '['
+	}
+	_, err = a.out.Write(append([]byte{separator}, out...))
+	if err == nil {
+		err = a.out.flush()
+	}
+	if err != nil {
+		a.err = err
+		return err
+	}
+
+	a.count++
+	return nil
+}
+
+// Close terminates the array. It returns the error that stopped the writer, if any, without
+// writing anything.
+func (a *JSONArrayWriter) Close() error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+
+	end := []byte("]")
+	if a.count == 0 {
+		end = []byte("[]")
+	}
+	_, err := a.out.Write(end)
+	if err != nil {
+		return err
+	}
+	return a.out.flush()
+}
+
+// WriteJSONSeq streams every value of seq to w as a JSON array. It stops at the first value
+// that can not be encoded or written, leaving the array unterminated.
+func WriteJSONSeq[T any](t *Tools, w http.ResponseWriter, status int, seq iter.Seq[T], headers ...http.Header) error {
+	a := t.NewJSONArrayWriter(w, status, headers...)
+	for v := range seq {
+		err := a.Write(v)
+		if err != nil {
+			return err
+		}
+	}
+	return a.Close()
+}
+
+// WriteJSONChan streams the values received from ch to w as a JSON array until ch is closed.
+// It stops at the first value that can not be encoded or written, leaving the array
+// unterminated; the producer should then be cancelled, since ch is no longer drained.
+func WriteJSONChan[T any](t *Tools, w http.ResponseWriter, status int, ch <-chan T, headers ...http.Header) error {
+	a := t.NewJSONArrayWriter(w, status, headers...)
+	for v := range ch {
+		err := a.Write(v)
+		if err != nil {
+			return err
+		}
+	}
+	return a.Close()
+}
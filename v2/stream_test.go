@@ -0,0 +1,113 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestTools_StreamJSON(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	headers := http.Header{"X-Report": []string{"monthly"}}
+	err := testTools.StreamJSON(rr, http.StatusCreated, JSONResponse{Message: "report"}, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusCreated || rr.Header().Get("X-Report") != "monthly" || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("wrong status or headers %d %v", rr.Code, rr.Header())
+	}
+	if rr.Body.String() != "{\"error\":false,\"message\":\"report\"}\n" {
+		t.Errorf("wrong body %s", rr.Body.String())
+	}
+
+	// nothing is sent when encoding fails before the first write, so an error can still be sent
+	rr = httptest.NewRecorder()
+	err = testTools.StreamJSON(rr, http.StatusOK, func() {})
+	if err == nil {
+		t.Fatal("error expected but none received")
+	}
+	_ = testTools.ErrorJSON(rr, err, http.StatusInternalServerError)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, rr.Code)
+	}
+}
+
+func TestWriteJSONSeq(t *testing.T) {
+	var testTools Tools
+
+	tests := []struct {
+		name          string
+		items         []interface{}
+		expected      string
+		errorExpected bool
+	}{
+		{name: "empty", items: nil, expected: "[]"},
+		{name: "items", items: []interface{}{1, "two", map[string]int{"three": 3}}, expected: `[1,"two",{"three":3}]`},
+		{name: "fails mid-stream", items: []interface{}{1, 2, func() {}, 4}, expected: "[1,2", errorExpected: true},
+		{name: "fails on first", items: []interface{}{func() {}}, expected: "", errorExpected: true},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		err := WriteJSONSeq(&testTools, rr, http.StatusOK, slices.Values(e.items))
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: error expected but none received", e.name)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: unexpected error %s", e.name, err)
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, rr.Body.String())
+		}
+		if e.expected != "" && !rr.Flushed {
+			t.Errorf("%s: response was not flushed", e.name)
+		}
+	}
+}
+
+func TestJSONArrayWriter_StopsAfterError(t *testing.T) {
+	var testTools Tools
+	rr := httptest.NewRecorder()
+
+	a := testTools.NewJSONArrayWriter(rr, http.StatusOK)
+	_ = a.Write(1)
+	encodeErr := a.Write(func() {})
+	if encodeErr == nil {
+		t.Fatal("error expected but none received")
+	}
+
+	if err := a.Write(2); !errors.Is(err, encodeErr) {
+		t.Errorf("expected %v but got %v", encodeErr, err)
+	}
+	if err := a.Close(); !errors.Is(err, encodeErr) {
+		t.Errorf("expected %v but got %v", encodeErr, err)
+	}
+	if rr.Body.String() != "[1" {
+		t.Errorf("expected unterminated array but got %s", rr.Body.String())
+	}
+}
+
+func TestWriteJSONChan(t *testing.T) {
+	var testTools Tools
+
+	ch := make(chan int)
+	go func() {
+		for i := 1; i <= 3; i++ {
+			ch <- i
+		}
+		close(ch)
+	}()
+
+	rr := httptest.NewRecorder()
+	err := WriteJSONChan(&testTools, rr, http.StatusOK, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Body.String() != "[1,2,3]" {
+		t.Errorf("wrong body %s", rr.Body.String())
+	}
+}
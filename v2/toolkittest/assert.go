@@ -0,0 +1,70 @@
+package toolkittest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	toolkit "github.com/nazmulcuet11/go-toolkit/v2"
+)
+
+// AssertJSON fails the test unless the recorded response has the given status, a JSON content
+// type and a body holding the same JSON value as want. A string or byte slice want is taken to
+// be JSON already.
+func AssertJSON(t testing.TB, rr *httptest.ResponseRecorder, status int, want interface{}) {
+	t.Helper()
+
+	if rr.Code != status {
+		t.Errorf("toolkittest: expected status %d but got %d", status, rr.Code)
+	}
+	if contentType := rr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		t.Errorf("toolkittest: expected a JSON content type but got %q", contentType)
+	}
+
+	equal, err := jsonEqual(rr.Body.Bytes(), want)
+	if err != nil {
+		t.Errorf("toolkittest: cannot compare JSON: %s", err)
+		return
+	}
+	if !equal {
+		expected, _ := normalizeJSON(want)
+		out, _ := json.Marshal(expected)
+		t.Errorf("toolkittest: expected body %s but got %s", out, strings.TrimSpace(rr.Body.String()))
+	}
+}
+
+// AssertErrorJSON fails the test unless the recorded response was written by ErrorJSON with
+// the given status and message. It expects the default JSONResponse body, not a custom
+// ErrorEnvelope.
+func AssertErrorJSON(t testing.TB, rr *httptest.ResponseRecorder, status int, message string) {
+	t.Helper()
+
+	if rr.Code != status {
+		t.Errorf("toolkittest: expected status %d but got %d", status, rr.Code)
+	}
+
+	var payload toolkit.JSONResponse
+	err := json.Unmarshal(rr.Body.Bytes(), &payload)
+	if err != nil {
+		t.Errorf("toolkittest: response is not a JSONResponse: %s", err)
+		return
+	}
+	if !payload.Error {
+		t.Error("toolkittest: expected error to be true")
+	}
+	if payload.Message != message {
+		t.Errorf("toolkittest: expected message %q but got %q", message, payload.Message)
+	}
+}
+
+// DecodeJSON decodes the body of the recorded response into v, failing the test when it is not
+// valid JSON
+func DecodeJSON(t testing.TB, rr *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	err := json.Unmarshal(rr.Body.Bytes(), v)
+	if err != nil {
+		t.Fatalf("toolkittest: cannot decode response: %s", err)
+	}
+}
@@ -0,0 +1,66 @@
+package toolkittest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	toolkit "github.com/nazmulcuet11/go-toolkit/v2"
+)
+
+func TestAssertJSON(t *testing.T) {
+	var tools toolkit.Tools
+
+	rr := httptest.NewRecorder()
+	_ = tools.WriteJSON(rr, http.StatusOK, map[string]interface{}{"name": "gopher", "tags": []string{"a"}})
+
+	var tests = []struct {
+		name     string
+		status   int
+		want     interface{}
+		failures int
+	}{
+		{name: "value", status: http.StatusOK, want: map[string]interface{}{"tags": []string{"a"}, "name": "gopher"}},
+		{name: "raw json", status: http.StatusOK, want: `{"tags":["a"],"name":"gopher"}`},
+		{name: "wrong status", status: http.StatusCreated, want: `{"tags":["a"],"name":"gopher"}`, failures: 1},
+		{name: "wrong body", status: http.StatusOK, want: `{"name":"gopher"}`, failures: 1},
+	}
+
+	for _, e := range tests {
+		ft := &fakeT{}
+		AssertJSON(ft, rr, e.status, e.want)
+		if len(ft.errors) != e.failures {
+			t.Errorf("%s: expected %d failures but got %v", e.name, e.failures, ft.errors)
+		}
+	}
+
+	var decoded struct{ Name string }
+	DecodeJSON(t, rr, &decoded)
+	if decoded.Name != "gopher" {
+		t.Errorf("expected gopher but got %q", decoded.Name)
+	}
+}
+
+func TestAssertErrorJSON(t *testing.T) {
+	var tools toolkit.Tools
+
+	rr := httptest.NewRecorder()
+	_ = tools.ErrorJSON(rr, errors.New("not allowed"), http.StatusForbidden)
+
+	AssertErrorJSON(t, rr, http.StatusForbidden, "not allowed")
+
+	ft := &fakeT{}
+	AssertErrorJSON(ft, rr, http.StatusBadRequest, "other")
+	if len(ft.errors) != 2 {
+		t.Errorf("expected 2 failures but got %v", ft.errors)
+	}
+
+	ok := httptest.NewRecorder()
+	_ = tools.WriteJSON(ok, http.StatusOK, toolkit.JSONResponse{Message: "fine"})
+	ft = &fakeT{}
+	AssertErrorJSON(ft, ok, http.StatusOK, "fine")
+	if len(ft.errors) != 1 {
+		t.Errorf("expected 1 failure but got %v", ft.errors)
+	}
+}
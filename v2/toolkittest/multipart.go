@@ -0,0 +1,92 @@
+package toolkittest
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// File is a file part of a multipart request
+type File struct {
+	// Field is the form field, "file" by default
+	Field string
+	Name  string
+	// ContentType is sent as the type of the part, application/octet-stream by default
+	ContentType string
+	Content     []byte
+}
+
+// ReadFile returns the file at path as the file part of field, failing the test when it cannot
+// be read
+func ReadFile(t testing.TB, field, path string) File {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("toolkittest: %s", err)
+	}
+	return File{Field: field, Name: filepath.Base(path), Content: content}
+}
+
+// NewUploadRequest returns a multipart/form-data POST request to target with the form values
+// and files, ready to be passed to a handler or to UploadFiles
+func NewUploadRequest(t testing.TB, target string, values url.Values, files ...File) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for key, vals := range values {
+		for _, value := range vals {
+			err := writer.WriteField(key, value)
+			if err != nil {
+				t.Fatalf("toolkittest: %s", err)
+			}
+		}
+	}
+
+	for _, file := range files {
+		field := file.Field
+		if field == "" {
+			field = "file"
+		}
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(field), escapeQuotes(file.Name)))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err == nil {
+			_, err = part.Write(file.Content)
+		}
+		if err != nil {
+			t.Fatalf("toolkittest: %s", err)
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		t.Fatalf("toolkittest: %s", err)
+	}
+
+	req := httptest.NewRequest("POST", target, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,36 @@
+package toolkittest
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	toolkit "github.com/nazmulcuet11/go-toolkit/v2"
+)
+
+func TestNewUploadRequest(t *testing.T) {
+	req := NewUploadRequest(t, "/upload", url.Values{"title": {"holiday"}},
+		ReadFile(t, "file", "../testdata/img.png"),
+		File{Name: "notes.txt", ContentType: "text/plain", Content: []byte("some notes")},
+	)
+
+	var tools toolkit.Tools
+	dir := t.TempDir()
+
+	files, err := tools.UploadFiles(req, dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].OriginalFileName != "img.png" || files[1].OriginalFileName != "notes.txt" {
+		t.Fatalf("unexpected files %+v", files)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "notes.txt"))
+	if err != nil || string(content) != "some notes" {
+		t.Errorf("wrong file content %q %v", content, err)
+	}
+	if req.FormValue("title") != "holiday" {
+		t.Errorf("expected form value holiday but got %q", req.FormValue("title"))
+	}
+}
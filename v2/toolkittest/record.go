@@ -0,0 +1,265 @@
+package toolkittest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	toolkit "github.com/nazmulcuet11/go-toolkit/v2"
+)
+
+// RecordEnv is the environment variable that switches recorders to recording, for example
+// TOOLKITTEST_RECORD=1 go test ./...
+const RecordEnv = "TOOLKITTEST_RECORD"
+
+// Exchange is a request and its response as saved in a golden file. Bodies that are not valid
+// UTF-8 are saved base64 encoded in RequestBodyBase64 and BodyBase64 instead.
+type Exchange struct {
+	Method            string      `json:"method"`
+	URL               string      `json:"url"`
+	RequestBody       string      `json:"request_body,omitempty"`
+	RequestBodyBase64 string      `json:"request_body_base64,omitempty"`
+	Status            int         `json:"status"`
+	Header            http.Header `json:"header,omitempty"`
+	Body              string      `json:"body,omitempty"`
+	BodyBase64        string      `json:"body_base64,omitempty"`
+}
+
+// redactedValue replaces the secrets left out of golden files
+const redactedValue = "[REDACTED]"
+
+var (
+	defaultRedactedHeaders = []string{"Set-Cookie"}
+	defaultRedactedQuery   = []string{"access_token", "api_key", "client_secret", "password", "token"}
+)
+
+// Recorder is an http.RoundTripper that replays the exchanges saved in a golden file, so tests
+// of code calling real services run offline. When RecordEnv is set it sends the requests
+// through Base instead and saves the exchanges to the golden file at the end of the test.
+//
+// Replayed requests are matched on method, URL and body, in the order they were recorded.
+// Request headers are not saved. The response headers, query parameters and JSON body fields
+// listed below are saved as [REDACTED], and requests are redacted the same way before they are
+// matched. Anything else is saved as sent, so review golden files before committing them.
+type Recorder struct {
+	// Base sends the requests while recording, http.DefaultTransport by default
+	Base http.RoundTripper
+	// RedactHeaders are response headers redacted in addition to Set-Cookie
+	RedactHeaders []string
+	// RedactQuery are query parameters redacted in addition to access_token, api_key,
+	// client_secret, password and token. Passwords in the URL are always redacted.
+	RedactQuery []string
+	// RedactFields are dotted paths of fields redacted in JSON request and response bodies
+	// with toolkit.RedactJSON
+	RedactFields []string
+
+	t         testing.TB
+	path      string
+	recording bool
+	mu        sync.Mutex
+	exchanges []Exchange
+	used      []bool
+}
+
+// NewRecorder returns a recorder for the golden file at path. When replaying, a missing or
+// invalid golden file fails the test.
+func NewRecorder(t testing.TB, path string) *Recorder {
+	t.Helper()
+
+	r := &Recorder{t: t, path: path, recording: os.Getenv(RecordEnv) != ""}
+	if r.recording {
+		t.Cleanup(r.save)
+		return r
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("toolkittest: cannot read golden file, run with %s=1 to record it: %s", RecordEnv, err)
+		return r
+	}
+	err = json.Unmarshal(content, &r.exchanges)
+	if err != nil {
+		t.Fatalf("toolkittest: invalid golden file %s: %s", path, err)
+		return r
+	}
+	r.used = make([]bool, len(r.exchanges))
+	return r
+}
+
+// Recording reports whether the recorder sends real requests
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// Client returns an http.Client using the recorder
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if r.recording {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	base := r.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	header := res.Header.Clone()
+	header.Del("Date")
+	for _, name := range append(defaultRedactedHeaders, r.RedactHeaders...) {
+		if values, ok := header[http.CanonicalHeaderKey(name)]; ok {
+			for i := range values {
+				values[i] = redactedValue
+			}
+		}
+	}
+
+	e := Exchange{Method: req.Method, URL: r.url(req), Status: res.StatusCode, Header: header}
+	e.RequestBody, e.RequestBodyBase64 = encodeBody(r.body(body, req.Header))
+	e.Body, e.BodyBase64 = encodeBody(r.body(resBody, res.Header))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exchanges = append(r.exchanges, e)
+	return res, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url := r.url(req)
+	text, encoded := encodeBody(r.body(body, req.Header))
+	for i, e := range r.exchanges {
+		if r.used[i] || e.Method != req.Method || e.URL != url || e.RequestBody != text || e.RequestBodyBase64 != encoded {
+			continue
+		}
+		resBody := []byte(e.Body)
+		if e.BodyBase64 != "" {
+			var err error
+			resBody, err = base64.StdEncoding.DecodeString(e.BodyBase64)
+			if err != nil {
+				return nil, fmt.Errorf("toolkittest: invalid body of %s %s in %s: %w", e.Method, e.URL, r.path, err)
+			}
+		}
+		r.used[i] = true
+
+		header := e.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+			StatusCode:    e.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(resBody)),
+			ContentLength: int64(len(resBody)),
+			Request:       req,
+		}, nil
+	}
+
+	r.t.Errorf("toolkittest: no recorded exchange for %s %s in %s", req.Method, req.URL, r.path)
+	return nil, fmt.Errorf("toolkittest: no recorded exchange for %s %s", req.Method, req.URL)
+}
+
+// url returns the URL of req as saved, with the password and the redacted query parameters
+// replaced
+func (r *Recorder) url(req *http.Request) string {
+	u := *req.URL
+	query := u.Query()
+	redacted := false
+	for key, values := range query {
+		for _, name := range append(defaultRedactedQuery, r.RedactQuery...) {
+			if strings.EqualFold(key, name) {
+				for i := range values {
+					values[i] = redactedValue
+				}
+				redacted = true
+			}
+		}
+	}
+	if redacted {
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// body returns a body as saved, with the redacted fields replaced when it is JSON
+func (r *Recorder) body(data []byte, header http.Header) []byte {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	if !isJSON || len(r.RedactFields) == 0 || len(data) == 0 {
+		return data
+	}
+
+	redacted, err := toolkit.RedactJSON(data, r.RedactFields)
+	if err != nil {
+		return data
+	}
+	return redacted
+}
+
+// encodeBody returns body as text, or base64 encoded when it is not valid UTF-8
+func encodeBody(body []byte) (text, encoded string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return "", base64.StdEncoding.EncodeToString(body)
+}
+
+// save writes the recorded exchanges to the golden file
+func (r *Recorder) save() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out, err := json.MarshalIndent(r.exchanges, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(r.path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(r.path, append(out, '\n'), 0644)
+	}
+	if err != nil {
+		r.t.Errorf("toolkittest: cannot save golden file %s: %s", r.path, err)
+	}
+}
@@ -0,0 +1,123 @@
+package toolkittest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `","body":"` + string(body) + `"}`))
+	}))
+	golden := filepath.Join(t.TempDir(), "testdata", "exchanges.json")
+
+	exchange := func(t *testing.T, client *http.Client) string {
+		res, err := client.Post(server.URL+"/echo", "text/plain", strings.NewReader("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.Header.Get("Content-Type") + " " + string(body)
+	}
+
+	expected := `application/json {"path":"/echo","body":"hello"}`
+
+	t.Run("record", func(t *testing.T) {
+		t.Setenv(RecordEnv, "1")
+		recorder := NewRecorder(t, golden)
+		if !recorder.Recording() {
+			t.Fatal("recorder is not recording")
+		}
+		if got := exchange(t, recorder.Client()); got != expected {
+			t.Errorf("expected %s but got %s", expected, got)
+		}
+	})
+
+	// the golden file is enough without the server
+	server.Close()
+
+	t.Run("replay", func(t *testing.T) {
+		t.Setenv(RecordEnv, "")
+		recorder := NewRecorder(t, golden)
+		if got := exchange(t, recorder.Client()); got != expected {
+			t.Errorf("expected %s but got %s", expected, got)
+		}
+
+		// every exchange is replayed once
+		ft := &fakeT{}
+		recorder.t = ft
+		_, err := recorder.Client().Post(server.URL+"/echo", "text/plain", strings.NewReader("hello"))
+		if err == nil || len(ft.errors) != 1 {
+			t.Errorf("expected a missing exchange but got %v %v", err, ft.errors)
+		}
+	})
+
+	t.Run("missing golden file", func(t *testing.T) {
+		t.Setenv(RecordEnv, "")
+		ft := &fakeT{}
+		NewRecorder(ft, filepath.Join(t.TempDir(), "missing.json"))
+		if len(ft.errors) != 1 {
+			t.Errorf("expected a failure but got %v", ft.errors)
+		}
+	})
+}
+
+func TestRecorder_Redacted(t *testing.T) {
+	binary := []byte{0xff, 0x00, 0xfe, 'a'}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=s3cret-cookie")
+		w.Header().Set("X-Api-Secret", "s3cret-header")
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(binary)
+	}))
+	defer server.Close()
+	golden := filepath.Join(t.TempDir(), "exchanges.json")
+
+	exchange := func(t *testing.T, recorder *Recorder) {
+		recorder.RedactHeaders = []string{"X-Api-Secret"}
+		recorder.RedactFields = []string{"password"}
+		res, err := recorder.Client().Post(server.URL+"/login?token=s3cret-token&page=2", "application/json",
+			strings.NewReader(`{"user":"bob","password":"s3cret-password"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		if !bytes.Equal(body, binary) {
+			t.Errorf("expected binary body but got %v", body)
+		}
+	}
+
+	t.Run("record", func(t *testing.T) {
+		t.Setenv(RecordEnv, "1")
+		exchange(t, NewRecorder(t, golden))
+	})
+
+	content, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "s3cret") {
+		t.Errorf("secrets saved in golden file %s", content)
+	}
+	for _, expected := range []string{`"body_base64": "/wD+YQ=="`, `page=2`, `\"password\":\"[REDACTED]\"`} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("expected %s in golden file %s", expected, content)
+		}
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		t.Setenv(RecordEnv, "")
+		exchange(t, NewRecorder(t, golden))
+	})
+}
@@ -0,0 +1,318 @@
+// Package toolkittest provides helpers for testing code built on the toolkit: fake and
+// record/replay HTTP transports for outgoing calls, multipart upload requests, and assertions
+// on the responses written by WriteJSON and ErrorJSON.
+package toolkittest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// RoundTripFunc lets an ordinary function be used as an http.RoundTripper
+type RoundTripFunc func(req *http.Request) *http.Response
+
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+// NewTestClient returns an http.Client whose requests are answered by f
+func NewTestClient(f RoundTripFunc) *http.Client {
+	return &http.Client{
+		Transport: f,
+	}
+}
+
+// Matcher reports whether a request matches an expectation. body is the request body, which
+// matchers can read without consuming it.
+type Matcher func(req *http.Request, body []byte) bool
+
+// Method matches requests with the given method
+func Method(method string) Matcher {
+	return func(req *http.Request, body []byte) bool {
+		return req.Method == method
+	}
+}
+
+// Path matches requests with the given URL path
+func Path(path string) Matcher {
+	return func(req *http.Request, body []byte) bool {
+		return req.URL.Path == path
+	}
+}
+
+// Query matches requests with the given query parameter value
+func Query(key, value string) Matcher {
+	return func(req *http.Request, body []byte) bool {
+		return req.URL.Query().Get(key) == value
+	}
+}
+
+// Header matches requests with the given header value
+func Header(key, value string) Matcher {
+	return func(req *http.Request, body []byte) bool {
+		return req.Header.Get(key) == value
+	}
+}
+
+// BodyContains matches requests whose body contains s
+func BodyContains(s string) Matcher {
+	return func(req *http.Request, body []byte) bool {
+		return bytes.Contains(body, []byte(s))
+	}
+}
+
+// JSONBody matches requests whose body is JSON equal to v once both are decoded, so formatting
+// and key order do not matter. A string or byte slice v is taken to be JSON already.
+func JSONBody(v interface{}) Matcher {
+	return func(req *http.Request, body []byte) bool {
+		equal, err := jsonEqual(body, v)
+		return err == nil && equal
+	}
+}
+
+// Expectation is a request expected by a FakeTransport and the response it gets. Without a
+// response, matching requests get an empty 200 OK.
+type Expectation struct {
+	method   string
+	path     string
+	matchers []Matcher
+
+	status int
+	header http.Header
+	body   []byte
+	err    error
+
+	times int
+	calls int
+}
+
+// Respond sets the status, body and headers of the response
+func (e *Expectation) Respond(status int, body string, headers ...http.Header) *Expectation {
+	e.status = status
+	e.body = []byte(body)
+	e.header = make(http.Header)
+	for _, h := range headers {
+		for key, values := range h {
+			e.header[key] = values
+		}
+	}
+	return e
+}
+
+// RespondJSON responds with data encoded as JSON
+func (e *Expectation) RespondJSON(status int, data interface{}, headers ...http.Header) *Expectation {
+	out, err := json.Marshal(data)
+	if err != nil {
+		panic(fmt.Sprintf("toolkittest: cannot encode response: %s", err))
+	}
+	e.Respond(status, string(out), headers...)
+	e.header.Set("Content-Type", "application/json")
+	return e
+}
+
+// Fail makes matching requests fail with err instead of getting a response, like a network
+// error
+func (e *Expectation) Fail(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Times limits the expectation to n requests. Once used up, later requests are matched against
+// the following expectations, which allows scripting a sequence of responses.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) String() string {
+	method, path := e.method, e.path
+	if method == "" {
+		method = "*"
+	}
+	if path == "" {
+		path = "*"
+	}
+	return method + " " + path
+}
+
+func (e *Expectation) matches(req *http.Request, body []byte) bool {
+	if e.times > 0 && e.calls >= e.times {
+		return false
+	}
+	if e.method != "" && req.Method != e.method {
+		return false
+	}
+	if e.path != "" && req.URL.Path != e.path {
+		return false
+	}
+	for _, match := range e.matchers {
+		if !match(req, body) {
+			return false
+		}
+	}
+	return true
+}
+
+// Request is a request received by a FakeTransport
+type Request struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// FakeTransport is an http.RoundTripper answering requests from a script of expectations.
+// Requests matching no expectation fail the test.
+type FakeTransport struct {
+	t            testing.TB
+	mu           sync.Mutex
+	expectations []*Expectation
+	requests     []Request
+}
+
+// NewFakeTransport returns a transport without expectations
+func NewFakeTransport(t testing.TB) *FakeTransport {
+	return &FakeTransport{t: t}
+}
+
+// On adds an expectation for requests with the given method and path, either of which may be
+// empty to match any, and the extra matchers. Expectations are tried in the order they were
+// added.
+func (f *FakeTransport) On(method, path string, matchers ...Matcher) *Expectation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e := &Expectation{method: method, path: path, matchers: matchers, status: http.StatusOK}
+	f.expectations = append(f.expectations, e)
+	return e
+}
+
+// Client returns an http.Client using the transport
+func (f *FakeTransport) Client() *http.Client {
+	return &http.Client{Transport: f}
+}
+
+func (f *FakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, Request{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+
+	for _, e := range f.expectations {
+		if !e.matches(req, body) {
+			continue
+		}
+		e.calls++
+		if e.err != nil {
+			return nil, e.err
+		}
+
+		header := e.header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+			StatusCode:    e.status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(e.body)),
+			ContentLength: int64(len(e.body)),
+			Request:       req,
+		}, nil
+	}
+
+	f.t.Errorf("toolkittest: unexpected request %s %s", req.Method, req.URL)
+	return nil, fmt.Errorf("toolkittest: no expectation matches %s %s", req.Method, req.URL)
+}
+
+// Requests returns the requests received so far
+func (f *FakeTransport) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Request(nil), f.requests...)
+}
+
+// AssertExpectations fails the test when an expectation was not met: expectations limited
+// with Times must have been used exactly that often, the others at least once
+func (f *FakeTransport) AssertExpectations() {
+	f.t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, e := range f.expectations {
+		switch {
+		case e.times > 0 && e.calls != e.times:
+			f.t.Errorf("toolkittest: expected %d requests for %s but got %d", e.times, e, e.calls)
+		case e.times == 0 && e.calls == 0:
+			f.t.Errorf("toolkittest: expected a request for %s but got none", e)
+		}
+	}
+}
+
+// jsonEqual reports whether data holds the same JSON value as v
+func jsonEqual(data []byte, v interface{}) (bool, error) {
+	want, err := normalizeJSON(v)
+	if err != nil {
+		return false, err
+	}
+	var got interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&got)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(got, want), nil
+}
+
+// normalizeJSON returns v as decoded from its JSON encoding. Strings and byte slices are taken
+// to be JSON already.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	var data []byte
+	switch v := v.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	case json.RawMessage:
+		data = v
+	default:
+		var err error
+		data, err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var normalized interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&normalized)
+	return normalized, err
+}
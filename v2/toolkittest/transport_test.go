@@ -0,0 +1,101 @@
+package toolkittest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	toolkit "github.com/nazmulcuet11/go-toolkit/v2"
+)
+
+// fakeT records the failures reported by the helpers under test
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Error(args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprint(args...))
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Fatalf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestFakeTransport(t *testing.T) {
+	ft := &fakeT{}
+	transport := NewFakeTransport(ft)
+	transport.On("POST", "/hook").Times(2).Respond(http.StatusServiceUnavailable, "")
+	transport.On("POST", "/hook", JSONBody(`{"n": 1}`), Header("Content-Type", "application/json")).
+		RespondJSON(http.StatusCreated, map[string]string{"id": "abc"})
+	transport.On("GET", "/down").Fail(errors.New("connection refused"))
+
+	var tools toolkit.Tools
+	opts := toolkit.PushOptions{
+		Client: transport.Client(),
+		Retry:  toolkit.RetryPolicy{MaxAttempts: 3, InitialBackoff: 1},
+	}
+	res, status, err := tools.PushJSONToRemoteContext(context.Background(), "http://someurl/hook", map[string]int{"n": 1}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if status != http.StatusCreated {
+		t.Errorf("expected status 201 but got %d", status)
+	}
+
+	requests := transport.Requests()
+	if len(requests) != 3 || string(requests[2].Body) != `{"n":1}` || requests[2].Header.Get("Idempotency-Key") == "" {
+		t.Errorf("unexpected requests %+v", requests)
+	}
+
+	_, err = transport.Client().Get("http://someurl/down")
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected network error but got %v", err)
+	}
+
+	transport.AssertExpectations()
+	if len(ft.errors) != 0 {
+		t.Errorf("unexpected failures %v", ft.errors)
+	}
+
+	// unexpected requests and unmet expectations fail the test
+	transport.On("DELETE", "/hook")
+	_, err = transport.Client().Get("http://someurl/other")
+	if err == nil {
+		t.Error("expected an error for an unexpected request")
+	}
+	transport.AssertExpectations()
+
+	expected := []string{
+		"toolkittest: unexpected request GET http://someurl/other",
+		"toolkittest: expected a request for DELETE /hook but got none",
+	}
+	if fmt.Sprint(ft.errors) != fmt.Sprint(expected) {
+		t.Errorf("expected failures %v but got %v", expected, ft.errors)
+	}
+}
+
+func TestNewTestClient(t *testing.T) {
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusTeapot, Body: http.NoBody, Header: make(http.Header)}
+	})
+
+	var tools toolkit.Tools
+	_, status, err := tools.PushJSONToRemote("http://someurl", map[string]int{"n": 1}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusTeapot {
+		t.Errorf("expected status 418 but got %d", status)
+	}
+}
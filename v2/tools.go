@@ -2,21 +2,21 @@ package toolkit
 
 import (
 	"bytes"
-	"crypto/rand"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 )
 
-const randomCharacterSet = "abcdefghijklijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ01223456789"
-
 // Tools is the type to instantiate the module. Any variable of this type have the access to all
 // the methods with the receiver *Tools.
 type Tools struct {
@@ -24,18 +24,59 @@ type Tools struct {
 	AllowedFileTypes   []string
 	MaxJSONSize        int
 	AllowUnknownFields bool
+	Codecs             []Codec
+
+	// FileNameGenerator returns the names, without extension, of the files renamed by UploadFiles
+	// and UploadFile, RandomString(32) by default. Wrap NewUUIDv7, NewULID or NewNanoID for
+	// sortable or shorter names.
+	FileNameGenerator func() (string, error)
+
+	// Opt-in limits applied when decoding JSON bodies. Zero values disable the limits.
+	// UseNumber decodes numbers into interface{} values as json.Number instead of float64, and
+	// CaseSensitiveFields rejects keys that only match a struct field when ignoring case.
+	MaxJSONDepth          int
+	MaxJSONArrayLength    int
+	MaxJSONStringLength   int
+	DisallowDuplicateKeys bool
+	UseNumber             bool
+	CaseSensitiveFields   bool
+
+	// JSONIndent indents JSON responses. Clients can also ask for indented responses with
+	// ?pretty=true on the methods that receive the request. DisableHTMLEscape writes <, > and &
+	// as is, and JSONEngine replaces encoding/json for encoding responses.
+	JSONIndent        string
+	DisableHTMLEscape bool
+	JSONEngine        JSONEngine
+
+	// SuccessEnvelope wraps the data written by WriteJSON and the other JSON writers, and
+	// ErrorEnvelope builds the body written by ErrorJSON in place of JSONResponse
+	SuccessEnvelope func(status int, data interface{}) interface{}
+	ErrorEnvelope   func(status int, err error) interface{}
+
+	// CompressResponses enables gzip compression of DownloadStaticFile and WriteBody responses
+	// larger than CompressionThreshold bytes (1 KB by default) when the client accepts it
+	CompressResponses    bool
+	CompressionThreshold int
+
+	// FieldsParam is the query parameter WriteJSONFields reads the selected fields from
+	FieldsParam     string
+	fieldAllowLists map[reflect.Type][]string
+
+	// DefaultPageSize and MaxPageSize are used by ReadPage, CursorSecret signs pagination cursors
+	DefaultPageSize int
+	MaxPageSize     int
+	CursorSecret    []byte
 }
 
-// RandomString returns a string of random character of length n.
+// RandomString returns a string of random character of length n, drawn uniformly from
+// AlphabetAlphanumeric. It panics when the system's source of randomness fails; use
+// RandomStringAlphabet to handle that error.
 func (t *Tools) RandomString(n int) string {
-	s := make([]rune, n)
-	r := []rune(randomCharacterSet)
-	for i := range s {
-		p, _ := rand.Prime(rand.Reader, 64)
-		index := p.Uint64() % uint64(len(r))
-		s[i] = r[index]
-	}
-	return string(s)
+	s, err := t.RandomStringAlphabet(n, AlphabetAlphanumeric)
+	if err != nil {
+		panic(err)
+	}
+	return s
 }
 
 // UploadedFile is a struct to save information about uploaded file
@@ -66,84 +107,118 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 
 	var uploadedFiles []*UploadedFile
 
-	if t.MaxFileSize == 0 {
-		t.MaxFileSize = 1 << 30 // 1 GB
-	}
-
 	err := t.CreateDirIfNotExists(uploadDir)
 	if err != nil {
 		return nil, err
 	}
 
-	err = r.ParseMultipartForm(int64(t.MaxFileSize))
+	err = r.ParseMultipartForm(int64(t.maxFileSize()))
 	if err != nil {
 		return nil, errors.New("uploaded file is too big")
 	}
 
 	for _, fileHeaders := range r.MultipartForm.File {
 		for _, fileHeader := range fileHeaders {
-			var uploadedFile UploadedFile
-			infile, err := fileHeader.Open()
+			uploadedFile, err := t.saveUploadedFile(fileHeader, uploadDir, renameFile)
 			if err != nil {
 				return uploadedFiles, err
 			}
-			defer infile.Close()
+			uploadedFiles = append(uploadedFiles, uploadedFile)
+		}
+	}
 
-			buff := make([]byte, 512)
-			_, err = infile.Read(buff)
-			if err != nil {
-				return uploadedFiles, err
-			}
+	return uploadedFiles, nil
+}
 
-			// check if file type is permitted
-			allowed := false
-			fileType := http.DetectContentType(buff)
-			if len(t.AllowedFileTypes) > 0 {
-				for _, x := range t.AllowedFileTypes {
-					if strings.EqualFold(x, fileType) {
-						allowed = true
-						break
-					}
-				}
-			} else {
-				allowed = true
-			}
+// saveUploadedFile checks the type of an uploaded file and copies it into uploadDir
+func (t *Tools) saveUploadedFile(fileHeader *multipart.FileHeader, uploadDir string, renameFile bool) (*UploadedFile, error) {
+	var uploadedFile UploadedFile
+	infile, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer infile.Close()
 
-			if !allowed {
-				return uploadedFiles, errors.New("uploaded file type is not permitted")
-			}
+	_, err = t.checkFileType(infile)
+	if err != nil {
+		return nil, err
+	}
 
-			_, err = infile.Seek(0, 0)
-			if err != nil {
-				return uploadedFiles, err
-			}
+	uploadedFile.OriginalFileName = fileHeader.Filename
+	if renameFile {
+		name, err := t.generateFileName()
+		if err != nil {
+			return nil, err
+		}
+		uploadedFile.NewFileName = fmt.Sprintf("%s%s", name, filepath.Ext(fileHeader.Filename))
+	} else {
+		uploadedFile.NewFileName = fileHeader.Filename
+	}
 
-			uploadedFile.OriginalFileName = fileHeader.Filename
-			if renameFile {
-				uploadedFile.NewFileName = fmt.Sprintf("%s%s", t.RandomString(32), filepath.Ext(fileHeader.Filename))
-			} else {
-				uploadedFile.NewFileName = fileHeader.Filename
-			}
+	outfile, err := os.Create(filepath.Join(uploadDir, uploadedFile.NewFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer outfile.Close()
 
-			var outfile *os.File
-			defer outfile.Close()
+	fileSize, err := io.Copy(outfile, infile)
+	if err != nil {
+		return nil, err
+	}
+	uploadedFile.FileSize = fileSize
 
-			outfile, err = os.Create(filepath.Join(uploadDir, uploadedFile.NewFileName))
-			if err != nil {
-				return uploadedFiles, err
-			} else {
-				fileSize, err := io.Copy(outfile, infile)
-				if err != nil {
-					return uploadedFiles, err
-				}
-				uploadedFile.FileSize = fileSize
-			}
+	return &uploadedFile, nil
+}
+
+// generateFileName returns a new name for an uploaded file
+func (t *Tools) generateFileName() (string, error) {
+	if t.FileNameGenerator == nil {
+		return randomString(32, AlphabetAlphanumeric)
+	}
 
-			uploadedFiles = append(uploadedFiles, &uploadedFile)
+	name, err := t.FileNameGenerator()
+	if err != nil {
+		return "", err
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("generated file name %q is invalid", name)
+	}
+	return name, nil
+}
+
+// checkFileType detects the content type of an uploaded file, checks it against AllowedFileTypes
+// and rewinds the file
+func (t *Tools) checkFileType(infile multipart.File) (string, error) {
+	buff := make([]byte, 512)
+	_, err := infile.Read(buff)
+	if err != nil {
+		return "", err
+	}
+
+	// check if file type is permitted
+	allowed := false
+	fileType := http.DetectContentType(buff)
+	if len(t.AllowedFileTypes) > 0 {
+		for _, x := range t.AllowedFileTypes {
+			if strings.EqualFold(x, fileType) {
+				allowed = true
+				break
+			}
 		}
+	} else {
+		allowed = true
 	}
 
-	return uploadedFiles, nil
+	if !allowed {
+		return "", errors.New("uploaded file type is not permitted")
+	}
+
+	_, err = infile.Seek(0, 0)
+	if err != nil {
+		return "", err
+	}
+
+	return fileType, nil
 }
 
 // CreateDirIfNotExists creates a directory if not exist
@@ -180,65 +255,99 @@ func (t *Tools) Slugify(s string) (string, error) {
 
 // DownloadStaticFile downloads a file, and tries to force the browser to avoid displaying it
 // in the browser window by setting content disposition. It also allows specification fo the
-// display name
+// display name. When CompressResponses is set compressible files are sent gzipped to clients
+// that accept it.
 func (t *Tools) DownloadStaticFile(w http.ResponseWriter, r *http.Request, filePath, displayName string) {
+	if t.CompressResponses && r.Header.Get("Range") == "" {
+		var done func()
+		w, done = t.compressResponse(w, r)
+		defer done()
+	}
+
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
 	http.ServeFile(w, r, filePath)
 }
 
+// StatusError is an error that carries the HTTP status code which should be sent to the client.
+// ErrorJSON uses the status code when no explicit status is given.
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
 type JSONResponse struct {
 	Error   bool        `json:"error"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
 
-func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
+// ReadJSON decodes a single JSON value from the request body into data. Gzip and deflate encoded
+// bodies are decompressed, and MaxJSONSize applies to the decompressed body. If a schema is
+// given, the body is validated against it before decoding and a *SchemaValidationError listing
+// the offending paths is returned when it does not match.
+func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}, schema ...*Schema) error {
+	err := t.limitBody(w, r)
+	if err != nil {
+		return err
+	}
+	if len(schema) == 0 || schema[0] == nil {
+		return t.decodeJSON(r.Body, data)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return jsonDecodeError(err)
+	}
+	err = validateJSONBody(body, schema[0])
+	if err != nil {
+		return err
+	}
+	return t.decodeJSON(bytes.NewReader(body), data)
+}
+
+// maxJSONSize returns the maximum size of a request body in bytes
+func (t *Tools) maxJSONSize() int {
 	maxBytes := 1 << 20 // 1 mega byte
 	if t.MaxJSONSize != 0 {
 		maxBytes = t.MaxJSONSize
 	}
+	return maxBytes
+}
+
+// decodeJSON decodes exactly one JSON value from body into data
+func (t *Tools) decodeJSON(body io.Reader, data interface{}) error {
+	if t.scansJSON() {
+		buf, err := io.ReadAll(body)
+		if err != nil {
+			return jsonDecodeError(err)
+		}
+		err = t.scanJSON(buf, reflect.TypeOf(data))
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
-	dec := json.NewDecoder(r.Body)
+	dec := json.NewDecoder(body)
 
 	if !t.AllowUnknownFields {
 		dec.DisallowUnknownFields()
 	}
+	if t.UseNumber {
+		dec.UseNumber()
+	}
 
 	err := dec.Decode(data)
 	if err != nil {
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
-		var invalidUnmarshalError *json.InvalidUnmarshalError
-		switch {
-		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
-
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
-
-		case errors.As(err, &unmarshalTypeError):
-			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
-			}
-			return fmt.Errorf("body contains incorrect JSON type (at charachter %d)", unmarshalTypeError.Offset)
-
-		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
-
-		case strings.HasPrefix(err.Error(), "json: unknown field"):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
-
-		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
-
-		case errors.As(err, &invalidUnmarshalError):
-			return fmt.Errorf("error unmarshaling JSON %s", err.Error())
-
-		default:
-			return err
-		}
+		return jsonDecodeError(err)
 	}
 
 	err = dec.Decode(&struct{}{})
@@ -249,12 +358,50 @@ func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{
 	return nil
 }
 
-func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
-	out, err := json.Marshal(data)
-	if err != nil {
+// jsonDecodeError converts an error returned by the JSON decoder into a message that is safe
+// to send back to the client
+func jsonDecodeError(err error) error {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+	var maxBytesError *http.MaxBytesError
+	switch {
+	case errors.As(err, &syntaxError):
+		return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("body contains badly-formed JSON")
+
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+		}
+		return fmt.Errorf("body contains incorrect JSON type (at charachter %d)", unmarshalTypeError.Offset)
+
+	case errors.Is(err, io.EOF):
+		return errors.New("body must not be empty")
+
+	case strings.HasPrefix(err.Error(), "json: unknown field"):
+		fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
+		return fmt.Errorf("body contains unknown key %s", fieldName)
+
+	case errors.As(err, &maxBytesError):
+		return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+
+	case errors.As(err, &invalidUnmarshalError):
+		return fmt.Errorf("error unmarshaling JSON %s", err.Error())
+
+	default:
 		return err
 	}
+}
 
+func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
+	return t.writeJSON(w, nil, status, data, headers...)
+}
+
+// writeJSONBytes writes an already encoded JSON body
+func (t *Tools) writeJSONBytes(w http.ResponseWriter, status int, out []byte, headers ...http.Header) error {
 	if len(headers) > 0 {
 		for key, value := range headers[0] {
 			w.Header()[key] = value
@@ -263,7 +410,7 @@ func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, h
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	_, err = w.Write(out)
+	_, err := w.Write(out)
 	if err != nil {
 		return err
 	}
@@ -273,44 +420,41 @@ func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, h
 
 func (t *Tools) ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
 	statusCode := http.StatusBadRequest
+	var statusError *StatusError
 	if len(status) > 0 {
 		statusCode = status[0]
+	} else if errors.As(err, &statusError) {
+		statusCode = statusError.Status
 	}
 
-	payload := JSONResponse{
+	var payload interface{} = JSONResponse{
 		Error:   true,
 		Message: err.Error(),
 	}
-	return t.WriteJSON(w, statusCode, payload)
-}
+	if t.ErrorEnvelope != nil {
+		payload = t.ErrorEnvelope(statusCode, err)
+	}
 
-func (t *Tools) PushJSONToRemote(uri string, data interface{}, client ...*http.Client) (*http.Response, int, error) {
-	// create json
-	jsonData, err := json.Marshal(data)
+	out, err := t.marshalJSON(payload, nil)
 	if err != nil {
-		return nil, 0, err
+		return err
 	}
+	return t.writeJSONBytes(w, statusCode, out)
+}
 
-	// check for custom http client
-	httClient := &http.Client{}
+// PushJSONToRemote posts data as JSON to uri. The body of the returned response is already
+// closed; use PushJSONToRemoteContext or a JSONClient to read the reply.
+func (t *Tools) PushJSONToRemote(uri string, data interface{}, client ...*http.Client) (*http.Response, int, error) {
+	var opts PushOptions
 	if len(client) > 0 {
-		httClient = client[0]
-	}
-
-	// build request
-	req, err := http.NewRequest("POST", uri, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, 0, err
+		opts.Client = client[0]
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	// call remote uri
-	res, err := httClient.Do(req)
+	res, status, err := t.PushJSONToRemoteContext(context.Background(), uri, data, opts)
 	if err != nil {
 		return nil, 0, err
 	}
-	defer res.Body.Close()
+	res.Body.Close()
 
-	// send the response back
-	return res, res.StatusCode, nil
+	return res, status, nil
 }